@@ -557,6 +557,14 @@ const (
 	AttrCurlyUnderline  Attr = 1 << 9
 	AttrDottedUnderline Attr = 1 << 10
 	AttrDashedUnderline Attr = 1 << 11
+	AttrOverline        Attr = 1 << 12
+	AttrFraktur         Attr = 1 << 13
+	AttrSuperscript     Attr = 1 << 14
+	AttrSubscript       Attr = 1 << 15
+
+	// AttrAnyUnderline matches every underline style.
+	AttrAnyUnderline = AttrUnderline | AttrDoubleUnderline | AttrCurlyUnderline |
+		AttrDottedUnderline | AttrDashedUnderline
 )
 
 // Has checks if the attribute set contains the given attribute.
@@ -593,12 +601,82 @@ const (
 	// Private modes (start at 0x200)
 	ModeApplicationCursor     Mode = 0x200 + 1
 	ModeApplicationKeypad     Mode = 0x200 + 2
-	ModeAlternateScreen       Mode = 0x200 + 3
+	ModeColumn                Mode = 0x200 + 3 // DECCOLM - 80/132 columns
+	ModeReverseVideo          Mode = 0x200 + 5 // DECSCNM
+	ModeOrigin                Mode = 0x200 + 6
+	ModeAutoWrap              Mode = 0x200 + 7
+	ModeBlinkingCursor        Mode = 0x200 + 12
 	ModeShowCursor            Mode = 0x200 + 25
+	ModeAllowColumnChange     Mode = 0x200 + 40 // allow DECCOLM
+	ModeAlternateScreenLegacy Mode = 0x200 + 47
+	ModeLeftRightMargin       Mode = 0x200 + 69
+	ModeAlternateScreenClear  Mode = 0x200 + 1047
 	ModeSaveRestoreCursor     Mode = 0x200 + 1048
+	ModeMouseReport           Mode = 0x200 + 1000 // report button presses and releases
+	ModeMouseDrag             Mode = 0x200 + 1002 // also report motion while a button is pressed
+	ModeMouseMotion           Mode = 0x200 + 1003 // also report all motion
+	ModeFocusEvents           Mode = 0x200 + 1004 // report focus in and out
+	ModeMouseSGR              Mode = 0x200 + 1006 // SGR encoding of mouse reports
 	ModeAlternateScreenBuffer Mode = 0x200 + 1049
 	ModeBracketedPaste        Mode = 0x200 + 2004
 	ModeSynchronizedOutput    Mode = 0x200 + 2026
+	ModeGraphemeClustering    Mode = 0x200 + 2027
+)
+
+// ModeAlternateScreen is private mode 3, which is DECCOLM.
+//
+// Deprecated: use ModeColumn.
+const ModeAlternateScreen = ModeColumn
+
+// recognizedModes are the modes DECRQM reports as set or reset unless the
+// embedder changes them with Processor.SetModeRecognized
+var recognizedModes = map[Mode]bool{
+	ModeKeyboardAction:        true,
+	ModeInsert:                true,
+	ModeSendReceive:           true,
+	ModeAutomaticNewline:      true,
+	ModeApplicationCursor:     true,
+	ModeApplicationKeypad:     true,
+	ModeColumn:                true,
+	ModeReverseVideo:          true,
+	ModeOrigin:                true,
+	ModeAutoWrap:              true,
+	ModeBlinkingCursor:        true,
+	ModeShowCursor:            true,
+	ModeAllowColumnChange:     true,
+	ModeAlternateScreenLegacy: true,
+	ModeLeftRightMargin:       true,
+	ModeAlternateScreenClear:  true,
+	ModeSaveRestoreCursor:     true,
+	ModeMouseReport:           true,
+	ModeMouseDrag:             true,
+	ModeMouseMotion:           true,
+	ModeFocusEvents:           true,
+	ModeMouseSGR:              true,
+	ModeAlternateScreenBuffer: true,
+	ModeBracketedPaste:        true,
+	ModeSynchronizedOutput:    true,
+	ModeGraphemeClustering:    true,
+}
+
+// ModeFromParam returns the mode selected by a SM/RM parameter; private is
+// true for DECSET/DECRST (CSI ? Pm h/l).
+func ModeFromParam(param uint16, private bool) Mode {
+	if private {
+		return Mode(0x200 + param)
+	}
+	return Mode(param)
+}
+
+// ModeState is the state of a mode in the reply to DECRQM (DECRPM).
+type ModeState uint8
+
+const (
+	ModeStateNotRecognized    ModeState = 0
+	ModeStateSet              ModeState = 1
+	ModeStateReset            ModeState = 2
+	ModeStatePermanentlySet   ModeState = 3
+	ModeStatePermanentlyReset ModeState = 4
 )
 
 // IsPrivate checks if this is a private mode.
@@ -660,6 +738,94 @@ func (m TabulationClearMode) String() string {
 	}
 }
 
+// Rectangle is a rectangular screen area used by the VT420 rectangular area
+// operations. Coordinates are 1-based and inclusive, and relative to the
+// margins in origin mode; a Bottom or Right of 0 means the last row or column.
+type Rectangle struct {
+	Top    int
+	Left   int
+	Bottom int
+	Right  int
+}
+
+// AttributeChangeExtent selects the area changed by DECCARA and DECRARA (DECSACE).
+type AttributeChangeExtent uint8
+
+const (
+	// AttributeChangeStream changes the characters from the start position to
+	// the end position, wrapping at the line ends
+	AttributeChangeStream AttributeChangeExtent = iota
+	// AttributeChangeRectangle changes the characters inside the rectangle
+	AttributeChangeRectangle
+)
+
+// LineSize is the size of the characters of a line (DECSWL, DECDWL, DECDHL).
+type LineSize uint8
+
+const (
+	// LineSizeSingle is a single-width line (DECSWL)
+	LineSizeSingle LineSize = iota
+	// LineSizeDoubleWidth is a double-width line (DECDWL)
+	LineSizeDoubleWidth
+	// LineSizeDoubleHeightTop is the top half of a double-height line (DECDHL)
+	LineSizeDoubleHeightTop
+	// LineSizeDoubleHeightBottom is the bottom half of a double-height line (DECDHL)
+	LineSizeDoubleHeightBottom
+)
+
+// TitleTarget selects the titles saved, restored or reported by window
+// operations (CSI 20-23 t).
+type TitleTarget uint8
+
+const (
+	// TitleBoth is the icon name and the window title
+	TitleBoth TitleTarget = iota
+	// TitleIconName is the icon name only
+	TitleIconName
+	// TitleWindow is the window title only
+	TitleWindow
+)
+
+// WindowOp is a window operation of CSI Ps t (XTWINOPS)
+type WindowOp int
+
+const (
+	WindowOpDeiconify          WindowOp = 1  // de-iconify the window
+	WindowOpIconify            WindowOp = 2  // iconify the window
+	WindowOpResize             WindowOp = 8  // resize the text area to lines and columns
+	WindowOpReportPixelSize    WindowOp = 14 // report the text area size in pixels
+	WindowOpReportCellSize     WindowOp = 16 // report the character cell size in pixels
+	WindowOpReportTextAreaSize WindowOp = 18 // report the text area size in characters
+	WindowOpReportIconName     WindowOp = 20 // report the icon name
+	WindowOpReportTitle        WindowOp = 21 // report the window title
+	WindowOpPushTitle          WindowOp = 22 // save the titles on the title stack
+	WindowOpPopTitle           WindowOp = 23 // restore the titles from the title stack
+	WindowOpResizeLines        WindowOp = 24 // DECSLPP - resize to Ps lines, any Ps >= 24
+)
+
+// DefaultWindowOps are the window operations a Processor allows initially:
+// the size reports and the title stack. Operations that change the window,
+// including DECSLPP, or report the titles must be allowed explicitly.
+var DefaultWindowOps = []WindowOp{
+	WindowOpReportPixelSize,
+	WindowOpReportCellSize,
+	WindowOpReportTextAreaSize,
+	WindowOpPushTitle,
+	WindowOpPopTitle,
+}
+
+// ParseTitleTarget parses the second parameter of CSI 22 t and CSI 23 t
+func ParseTitleTarget(param int) TitleTarget {
+	switch param {
+	case 1:
+		return TitleIconName
+	case 2:
+		return TitleWindow
+	default:
+		return TitleBoth
+	}
+}
+
 // C0 defines C0 control characters (0x00-0x1F).
 var C0 = struct {
 	NUL byte // Null
@@ -800,6 +966,36 @@ const (
 	StandardCharsetASCII StandardCharset = iota
 	// StandardCharsetSpecialLineDrawing is the special character and line drawing set
 	StandardCharsetSpecialLineDrawing
+	// StandardCharsetUK is the United Kingdom national set
+	StandardCharsetUK
+	// StandardCharsetDECSupplemental is the DEC Supplemental Graphic set
+	StandardCharsetDECSupplemental
+	// StandardCharsetDECTechnical is the DEC Technical set
+	StandardCharsetDECTechnical
+	// StandardCharsetISOLatin1 is the ISO Latin-1 supplemental 96-character set
+	StandardCharsetISOLatin1
+	// StandardCharsetDutch is the Dutch national replacement set
+	StandardCharsetDutch
+	// StandardCharsetFinnish is the Finnish national replacement set
+	StandardCharsetFinnish
+	// StandardCharsetFrench is the French national replacement set
+	StandardCharsetFrench
+	// StandardCharsetFrenchCanadian is the French Canadian national replacement set
+	StandardCharsetFrenchCanadian
+	// StandardCharsetGerman is the German national replacement set
+	StandardCharsetGerman
+	// StandardCharsetItalian is the Italian national replacement set
+	StandardCharsetItalian
+	// StandardCharsetNorwegianDanish is the Norwegian/Danish national replacement set
+	StandardCharsetNorwegianDanish
+	// StandardCharsetPortuguese is the Portuguese national replacement set
+	StandardCharsetPortuguese
+	// StandardCharsetSpanish is the Spanish national replacement set
+	StandardCharsetSpanish
+	// StandardCharsetSwedish is the Swedish national replacement set
+	StandardCharsetSwedish
+	// StandardCharsetSwiss is the Swiss national replacement set
+	StandardCharsetSwiss
 )
 
 // String returns the string representation of StandardCharset
@@ -809,6 +1005,36 @@ func (s StandardCharset) String() string {
 		return "ASCII"
 	case StandardCharsetSpecialLineDrawing:
 		return "SpecialCharacterAndLineDrawing"
+	case StandardCharsetUK:
+		return "UK"
+	case StandardCharsetDECSupplemental:
+		return "DECSupplementalGraphic"
+	case StandardCharsetDECTechnical:
+		return "DECTechnical"
+	case StandardCharsetISOLatin1:
+		return "ISOLatin1Supplemental"
+	case StandardCharsetDutch:
+		return "Dutch"
+	case StandardCharsetFinnish:
+		return "Finnish"
+	case StandardCharsetFrench:
+		return "French"
+	case StandardCharsetFrenchCanadian:
+		return "FrenchCanadian"
+	case StandardCharsetGerman:
+		return "German"
+	case StandardCharsetItalian:
+		return "Italian"
+	case StandardCharsetNorwegianDanish:
+		return "NorwegianDanish"
+	case StandardCharsetPortuguese:
+		return "Portuguese"
+	case StandardCharsetSpanish:
+		return "Spanish"
+	case StandardCharsetSwedish:
+		return "Swedish"
+	case StandardCharsetSwiss:
+		return "Swiss"
 	default:
 		return "Unknown"
 	}
@@ -822,8 +1048,14 @@ func (s StandardCharset) Map(c rune) rune {
 		return c
 	case StandardCharsetSpecialLineDrawing:
 		return mapSpecialLineDrawing(c)
+	case StandardCharsetDECSupplemental:
+		return mapDECSupplemental(c)
+	case StandardCharsetDECTechnical:
+		return mapDECTechnical(c)
+	case StandardCharsetISOLatin1:
+		return mapISOLatin1(c)
 	default:
-		return c
+		return mapNationalReplacement(s, c)
 	}
 }
 
@@ -192,7 +192,7 @@ func TestMode(t *testing.T) {
 		// Private modes
 		assert.True(t, ModeApplicationCursor.IsPrivate())
 		assert.True(t, ModeApplicationKeypad.IsPrivate())
-		assert.True(t, ModeAlternateScreen.IsPrivate())
+		assert.True(t, ModeColumn.IsPrivate())
 		assert.True(t, ModeShowCursor.IsPrivate())
 		assert.True(t, ModeBracketedPaste.IsPrivate())
 		assert.True(t, ModeSynchronizedOutput.IsPrivate())
@@ -201,7 +201,7 @@ func TestMode(t *testing.T) {
 	t.Run("UniqueValues", func(t *testing.T) {
 		modes := []Mode{
 			ModeKeyboardAction, ModeInsert, ModeReplace, ModeSendReceive, ModeAutomaticNewline,
-			ModeApplicationCursor, ModeApplicationKeypad, ModeAlternateScreen,
+			ModeApplicationCursor, ModeApplicationKeypad, ModeColumn,
 			ModeShowCursor, ModeSaveRestoreCursor, ModeAlternateScreenBuffer,
 			ModeBracketedPaste, ModeSynchronizedOutput,
 		}
@@ -502,13 +502,13 @@ func TestProcessorAdvanced(t *testing.T) {
 		assert.True(t, processor.IsMode(ModeApplicationCursor))
 
 		// Push another mode
-		processor.SetMode(ModeAlternateScreen, true)
-		assert.True(t, processor.IsMode(ModeAlternateScreen))
+		processor.SetMode(ModeColumn, true)
+		assert.True(t, processor.IsMode(ModeColumn))
 		assert.True(t, processor.IsMode(ModeApplicationCursor))
 
 		// Pop modes
-		processor.SetMode(ModeAlternateScreen, false)
-		assert.False(t, processor.IsMode(ModeAlternateScreen))
+		processor.SetMode(ModeColumn, false)
+		assert.False(t, processor.IsMode(ModeColumn))
 		assert.True(t, processor.IsMode(ModeApplicationCursor))
 	})
 
@@ -0,0 +1,207 @@
+package govte
+
+// ParseCharsetDesignation parses the intermediates and final byte of an SCS
+// (Select Character Set) escape sequence. It returns the designated slot and
+// character set, and false when the sequence does not designate a known set.
+//
+// The intermediates '(', ')', '*' and '+' designate a 94-character set as
+// G0-G3; '-', '.' and '/' designate a 96-character set as G1-G3. A second
+// intermediate selects the multi-byte final characters such as "%5".
+func ParseCharsetDesignation(intermediates []byte, final byte) (CharsetIndex, StandardCharset, bool) {
+	if len(intermediates) == 0 || len(intermediates) > 2 {
+		return 0, 0, false
+	}
+
+	var index CharsetIndex
+	ninetySix := false
+	switch intermediates[0] {
+	case '(':
+		index = G0
+	case ')':
+		index = G1
+	case '*':
+		index = G2
+	case '+':
+		index = G3
+	case '-':
+		index, ninetySix = G1, true
+	case '.':
+		index, ninetySix = G2, true
+	case '/':
+		index, ninetySix = G3, true
+	default:
+		return 0, 0, false
+	}
+
+	var charset StandardCharset
+	var ok bool
+	switch {
+	case ninetySix:
+		charset, ok = ninetySixCharset(intermediates[1:], final)
+	default:
+		charset, ok = ninetyFourCharset(intermediates[1:], final)
+	}
+	return index, charset, ok
+}
+
+// ninetyFourCharset identifies a 94-character set by its final characters
+func ninetyFourCharset(intermediates []byte, final byte) (StandardCharset, bool) {
+	if len(intermediates) == 1 {
+		if intermediates[0] != '%' {
+			return 0, false
+		}
+		switch final {
+		case '5':
+			return StandardCharsetDECSupplemental, true
+		case '6':
+			return StandardCharsetPortuguese, true
+		}
+		return 0, false
+	}
+
+	switch final {
+	case 'B':
+		return StandardCharsetASCII, true
+	case '0':
+		return StandardCharsetSpecialLineDrawing, true
+	case 'A':
+		return StandardCharsetUK, true
+	case '<':
+		return StandardCharsetDECSupplemental, true
+	case '>':
+		return StandardCharsetDECTechnical, true
+	case '4':
+		return StandardCharsetDutch, true
+	case 'C', '5':
+		return StandardCharsetFinnish, true
+	case 'R', 'f':
+		return StandardCharsetFrench, true
+	case 'Q', '9':
+		return StandardCharsetFrenchCanadian, true
+	case 'K':
+		return StandardCharsetGerman, true
+	case 'Y':
+		return StandardCharsetItalian, true
+	case 'E', '6', '`':
+		return StandardCharsetNorwegianDanish, true
+	case 'Z':
+		return StandardCharsetSpanish, true
+	case 'H', '7':
+		return StandardCharsetSwedish, true
+	case '=':
+		return StandardCharsetSwiss, true
+	}
+	return 0, false
+}
+
+// ninetySixCharset identifies a 96-character set by its final characters
+func ninetySixCharset(intermediates []byte, final byte) (StandardCharset, bool) {
+	if len(intermediates) == 0 && final == 'A' {
+		return StandardCharsetISOLatin1, true
+	}
+	return 0, false
+}
+
+// mapDECSupplemental maps characters for the DEC Supplemental Graphic set,
+// which mostly matches the upper half of ISO Latin-1
+func mapDECSupplemental(c rune) rune {
+	switch c {
+	case '(':
+		return '¤'
+	case 'W':
+		return 'Œ'
+	case ']':
+		return 'Ÿ'
+	case 'w':
+		return 'œ'
+	case '}':
+		return 'ÿ'
+	}
+	if c >= 0x21 && c <= 0x7E {
+		return c + 0x80
+	}
+	return c
+}
+
+// mapISOLatin1 maps characters for the ISO Latin-1 supplemental set
+func mapISOLatin1(c rune) rune {
+	if c >= 0x20 && c <= 0x7F {
+		return c + 0x80
+	}
+	return c
+}
+
+// decTechnical holds the DEC Technical set for 0x21-0x7E; zero entries are
+// undefined and print unchanged
+var decTechnical = [...]rune{
+	'⎷', '┌', '─', '⌠', '⌡', '│', '⎡', '⎣', '⎤', '⎦', '⎛', '⎝', '⎞', '⎠', '⎨', // 0x21-0x2F
+	'⎬', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, '≤', '≠', '≥', '∫', // 0x30-0x3F
+	'∴', '∝', '∞', '÷', 'Δ', '∇', 'Φ', 'Γ', '∼', '≃', 'Θ', '×', 'Λ', '⇔', '⇒', '≡', // 0x40-0x4F
+	'Π', 'Ψ', 0, 'Σ', 0, 0, '√', 'Ω', 'Ξ', 'Υ', '⊂', '⊃', '∩', '∪', '∧', '∨', // 0x50-0x5F
+	'¬', 'α', 'β', 'χ', 'δ', 'ε', 'φ', 'γ', 'η', 'ι', 'θ', 'κ', 'λ', 0, 'ν', '∂', // 0x60-0x6F
+	'π', 'ψ', 'ρ', 'σ', 'τ', 0, 'ƒ', 'ω', 'ξ', 'υ', 'ζ', '←', '↑', '→', '↓', // 0x70-0x7E
+}
+
+// mapDECTechnical maps characters for the DEC Technical set
+func mapDECTechnical(c rune) rune {
+	if c >= 0x21 && c <= 0x7E && decTechnical[c-0x21] != 0 {
+		return decTechnical[c-0x21]
+	}
+	return c
+}
+
+// nationalReplacements lists the characters each national set replaces in ASCII
+var nationalReplacements = map[StandardCharset]map[rune]rune{
+	StandardCharsetUK: {'#': '£'},
+	StandardCharsetDutch: {
+		'#': '£', '@': '¾', '[': 'ĳ', '\\': '½', ']': '|',
+		'{': '¨', '|': 'ƒ', '}': '¼', '~': '´',
+	},
+	StandardCharsetFinnish: {
+		'[': 'Ä', '\\': 'Ö', ']': 'Å', '^': 'Ü', '`': 'é',
+		'{': 'ä', '|': 'ö', '}': 'å', '~': 'ü',
+	},
+	StandardCharsetFrench: {
+		'#': '£', '@': 'à', '[': '°', '\\': 'ç', ']': '§',
+		'{': 'é', '|': 'ù', '}': 'è', '~': '¨',
+	},
+	StandardCharsetFrenchCanadian: {
+		'@': 'à', '[': 'â', '\\': 'ç', ']': 'ê', '^': 'î',
+		'`': 'ô', '{': 'é', '|': 'ù', '}': 'è', '~': 'û',
+	},
+	StandardCharsetGerman: {
+		'@': '§', '[': 'Ä', '\\': 'Ö', ']': 'Ü',
+		'{': 'ä', '|': 'ö', '}': 'ü', '~': 'ß',
+	},
+	StandardCharsetItalian: {
+		'#': '£', '@': '§', '[': '°', '\\': 'ç', ']': 'é',
+		'`': 'ù', '{': 'à', '|': 'ò', '}': 'è', '~': 'ì',
+	},
+	StandardCharsetNorwegianDanish: {
+		'@': 'Ä', '[': 'Æ', '\\': 'Ø', ']': 'Å', '^': 'Ü',
+		'`': 'ä', '{': 'æ', '|': 'ø', '}': 'å', '~': 'ü',
+	},
+	StandardCharsetPortuguese: {
+		'[': 'Ã', '\\': 'Ç', ']': 'Õ', '{': 'ã', '|': 'ç', '}': 'õ',
+	},
+	StandardCharsetSpanish: {
+		'#': '£', '@': '§', '[': '¡', '\\': 'Ñ', ']': '¿',
+		'{': '°', '|': 'ñ', '}': 'ç',
+	},
+	StandardCharsetSwedish: {
+		'@': 'É', '[': 'Ä', '\\': 'Ö', ']': 'Å', '^': 'Ü',
+		'`': 'é', '{': 'ä', '|': 'ö', '}': 'å', '~': 'ü',
+	},
+	StandardCharsetSwiss: {
+		'#': 'ù', '@': 'à', '[': 'é', '\\': 'ç', ']': 'ê', '^': 'î',
+		'_': 'è', '`': 'ô', '{': 'ä', '|': 'ö', '}': 'ü', '~': 'û',
+	},
+}
+
+// mapNationalReplacement maps characters for the national replacement sets
+func mapNationalReplacement(s StandardCharset, c rune) rune {
+	if mapped, ok := nationalReplacements[s][c]; ok {
+		return mapped
+	}
+	return c
+}
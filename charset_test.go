@@ -302,3 +302,68 @@ func TestCharsetReset(t *testing.T) {
 	// (The actual behavior depends on implementation)
 	assert.NotNil(t, processor, "Processor should still be valid after reset")
 }
+
+func TestCharsetDesignations(t *testing.T) {
+	processor := NewProcessor(&NoopHandler{})
+	handler := &CharsetHandler{}
+
+	tests := []struct {
+		sequence        string
+		expectedIndex   CharsetIndex
+		expectedCharset StandardCharset
+	}{
+		{"\x1b(A", G0, StandardCharsetUK},
+		{"\x1b)%5", G1, StandardCharsetDECSupplemental},
+		{"\x1b*<", G2, StandardCharsetDECSupplemental},
+		{"\x1b+>", G3, StandardCharsetDECTechnical},
+		{"\x1b-A", G1, StandardCharsetISOLatin1},
+		{"\x1b/A", G3, StandardCharsetISOLatin1},
+		{"\x1b(K", G0, StandardCharsetGerman},
+		{"\x1b(%6", G0, StandardCharsetPortuguese},
+		{"\x1b(7", G0, StandardCharsetSwedish},
+		{"\x1b)=", G1, StandardCharsetSwiss},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expectedCharset.String(), func(t *testing.T) {
+			handler.charsetConfigs = nil
+			processor.Advance(handler, []byte(tt.sequence))
+
+			assert.Len(t, handler.charsetConfigs, 1)
+			assert.Equal(t, tt.expectedIndex, handler.charsetConfigs[0].Index)
+			assert.Equal(t, tt.expectedCharset, handler.charsetConfigs[0].Charset)
+		})
+	}
+
+	// Unknown sets and 96-character sets as G0 are ignored
+	handler.charsetConfigs = nil
+	processor.Advance(handler, []byte("\x1b(%9\x1b-B\x1b#8"))
+	assert.Len(t, handler.charsetConfigs, 0)
+}
+
+func TestNationalCharacterMapping(t *testing.T) {
+	tests := []struct {
+		charset  StandardCharset
+		input    rune
+		expected rune
+	}{
+		{StandardCharsetUK, '#', '£'},
+		{StandardCharsetUK, '[', '['},
+		{StandardCharsetGerman, '{', 'ä'},
+		{StandardCharsetGerman, '~', 'ß'},
+		{StandardCharsetFrench, '@', 'à'},
+		{StandardCharsetSwedish, '@', 'É'},
+		{StandardCharsetSpanish, '\\', 'Ñ'},
+		{StandardCharsetDECSupplemental, '1', '±'},
+		{StandardCharsetDECSupplemental, 'W', 'Œ'},
+		{StandardCharsetDECTechnical, 'a', 'α'},
+		{StandardCharsetDECTechnical, '1', '1'},
+		{StandardCharsetISOLatin1, 'A', 'Á'},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.charset.String()+"/"+string(tt.input), func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.charset.Map(tt.input))
+		})
+	}
+}
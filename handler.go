@@ -40,6 +40,37 @@ type Handler interface {
 	// SetTitle sets the window title.
 	SetTitle(title string)
 
+	// SetLineSize sets the size of the characters of the cursor line (ESC # 3/4/5/6).
+	SetLineSize(size LineSize)
+
+	// ScreenAlignmentTest fills the screen with 'E' (DECALN, ESC # 8).
+	ScreenAlignmentTest()
+
+	// SetIconName sets the icon name (OSC 1).
+	SetIconName(name string)
+
+	// PushTitle saves the icon name, the window title or both on the title stack.
+	PushTitle(target TitleTarget)
+
+	// PopTitle restores the icon name, the window title or both from the title stack.
+	PopTitle(target TitleTarget)
+
+	// ReportTitle asks for the icon name (CSI 20 t) or the window title (CSI 21 t).
+	ReportTitle(target TitleTarget)
+
+	// SetIconified iconifies (CSI 2 t) or de-iconifies (CSI 1 t) the window.
+	SetIconified(iconified bool)
+
+	// ResizeTextArea asks to resize the text area in characters (CSI 8 ; lines ; columns t).
+	ResizeTextArea(columns, lines int)
+
+	// Notify delivers a desktop notification (OSC 9, OSC 777 or OSC 99).
+	Notify(notification Notification)
+
+	// SetProgress reports the progress of a long-running task (OSC 9 ; 4).
+	// percent is in the range 0-100.
+	SetProgress(state ProgressState, percent int)
+
 	// Cursor Movement
 
 	// Goto moves cursor to absolute position (1-based).
@@ -100,6 +131,39 @@ type Handler interface {
 	// ClearScreen clears screen according to mode.
 	ClearScreen(mode ClearMode)
 
+	// SelectiveClearLine clears the unprotected characters of the line
+	// according to mode (DECSEL).
+	SelectiveClearLine(mode LineClearMode)
+
+	// SelectiveClearScreen clears the unprotected characters of the screen
+	// according to mode (DECSED).
+	SelectiveClearScreen(mode ClearMode)
+
+	// Rectangular Area Operations
+
+	// FillRectangle fills the area with c using the current attributes (DECFRA).
+	FillRectangle(c rune, area Rectangle)
+
+	// EraseRectangle erases the area (DECERA).
+	EraseRectangle(area Rectangle)
+
+	// SelectiveEraseRectangle erases the unprotected characters of the area (DECSERA).
+	SelectiveEraseRectangle(area Rectangle)
+
+	// CopyRectangle copies the area to the given top-left position, 1-based (DECCRA).
+	CopyRectangle(area Rectangle, top, left int)
+
+	// ChangeRectangleAttributes applies the SGR attributes 0, 1, 4, 5, 7, 22,
+	// 24, 25 and 27 to the area (DECCARA).
+	ChangeRectangleAttributes(area Rectangle, attrs []int)
+
+	// ReverseRectangleAttributes toggles the SGR attributes 1, 4, 5 and 7, or
+	// all of them for 0, in the area (DECRARA).
+	ReverseRectangleAttributes(area Rectangle, attrs []int)
+
+	// SetAttributeChangeExtent selects the area changed by DECCARA and DECRARA (DECSACE).
+	SetAttributeChangeExtent(extent AttributeChangeExtent)
+
 	// ScrollUp scrolls screen up by n lines.
 	ScrollUp(lines int)
 
@@ -109,11 +173,31 @@ type Handler interface {
 	// SetScrollingRegion sets the scrolling region (1-based).
 	SetScrollingRegion(top, bottom int)
 
+	// SetLeftRightMargins sets the left and right margins (1-based, DECSLRM).
+	// A right margin of 0 means the last column.
+	SetLeftRightMargins(left, right int)
+
+	// SetColumnsPerPage sets the number of columns: 80 or 132 (DECSCPP).
+	SetColumnsPerPage(columns int)
+
+	// SetLinesPerPage sets the number of lines, at least 24 (DECSLPP).
+	SetLinesPerPage(lines int)
+
 	// Text Attributes
 
 	// SetAttribute sets text rendering attribute.
 	SetAttribute(attr Attr)
 
+	// UnsetAttribute clears the given text rendering attributes.
+	UnsetAttribute(attr Attr)
+
+	// SetFont selects the primary font (0) or an alternate font (1-9).
+	SetFont(font int)
+
+	// SetCharacterProtection sets whether characters written from now on are
+	// protected from selective erase (DECSCA, SPA/EPA).
+	SetCharacterProtection(protected bool)
+
 	// ResetAttributes resets all text attributes to default.
 	ResetAttributes()
 
@@ -123,7 +207,11 @@ type Handler interface {
 	// SetBackground sets background color.
 	SetBackground(color Color)
 
-	// ResetColors resets colors to default.
+	// SetUnderlineColor sets the underline color.
+	// The named Foreground color means the underline follows the text color.
+	SetUnderlineColor(color Color)
+
+	// ResetColors resets colors, including the underline color, to default.
 	ResetColors()
 
 	// Cursor Appearance
@@ -225,6 +313,36 @@ func (h *NoopHandler) TabBackward(count int) {}
 // SetTitle implements Handler.
 func (h *NoopHandler) SetTitle(title string) {}
 
+// SetLineSize implements Handler.
+func (h *NoopHandler) SetLineSize(size LineSize) {}
+
+// ScreenAlignmentTest implements Handler.
+func (h *NoopHandler) ScreenAlignmentTest() {}
+
+// SetIconName implements Handler.
+func (h *NoopHandler) SetIconName(name string) {}
+
+// PushTitle implements Handler.
+func (h *NoopHandler) PushTitle(target TitleTarget) {}
+
+// PopTitle implements Handler.
+func (h *NoopHandler) PopTitle(target TitleTarget) {}
+
+// ReportTitle implements Handler.
+func (h *NoopHandler) ReportTitle(target TitleTarget) {}
+
+// SetIconified implements Handler.
+func (h *NoopHandler) SetIconified(iconified bool) {}
+
+// ResizeTextArea implements Handler.
+func (h *NoopHandler) ResizeTextArea(columns, lines int) {}
+
+// Notify implements Handler.
+func (h *NoopHandler) Notify(notification Notification) {}
+
+// SetProgress implements Handler.
+func (h *NoopHandler) SetProgress(state ProgressState, percent int) {}
+
 // Goto implements Handler.
 func (h *NoopHandler) Goto(line, col int) {}
 
@@ -279,6 +397,33 @@ func (h *NoopHandler) ClearLine(mode LineClearMode) {}
 // ClearScreen implements Handler.
 func (h *NoopHandler) ClearScreen(mode ClearMode) {}
 
+// SelectiveClearLine implements Handler.
+func (h *NoopHandler) SelectiveClearLine(mode LineClearMode) {}
+
+// SelectiveClearScreen implements Handler.
+func (h *NoopHandler) SelectiveClearScreen(mode ClearMode) {}
+
+// FillRectangle implements Handler.
+func (h *NoopHandler) FillRectangle(c rune, area Rectangle) {}
+
+// EraseRectangle implements Handler.
+func (h *NoopHandler) EraseRectangle(area Rectangle) {}
+
+// SelectiveEraseRectangle implements Handler.
+func (h *NoopHandler) SelectiveEraseRectangle(area Rectangle) {}
+
+// CopyRectangle implements Handler.
+func (h *NoopHandler) CopyRectangle(area Rectangle, top, left int) {}
+
+// ChangeRectangleAttributes implements Handler.
+func (h *NoopHandler) ChangeRectangleAttributes(area Rectangle, attrs []int) {}
+
+// ReverseRectangleAttributes implements Handler.
+func (h *NoopHandler) ReverseRectangleAttributes(area Rectangle, attrs []int) {}
+
+// SetAttributeChangeExtent implements Handler.
+func (h *NoopHandler) SetAttributeChangeExtent(extent AttributeChangeExtent) {}
+
 // ScrollUp implements Handler.
 func (h *NoopHandler) ScrollUp(lines int) {}
 
@@ -288,9 +433,27 @@ func (h *NoopHandler) ScrollDown(lines int) {}
 // SetScrollingRegion implements Handler.
 func (h *NoopHandler) SetScrollingRegion(top, bottom int) {}
 
+// SetLeftRightMargins implements Handler.
+func (h *NoopHandler) SetLeftRightMargins(left, right int) {}
+
+// SetColumnsPerPage implements Handler.
+func (h *NoopHandler) SetColumnsPerPage(columns int) {}
+
+// SetLinesPerPage implements Handler.
+func (h *NoopHandler) SetLinesPerPage(lines int) {}
+
 // SetAttribute implements Handler.
 func (h *NoopHandler) SetAttribute(attr Attr) {}
 
+// UnsetAttribute implements Handler.
+func (h *NoopHandler) UnsetAttribute(attr Attr) {}
+
+// SetFont implements Handler.
+func (h *NoopHandler) SetFont(font int) {}
+
+// SetCharacterProtection implements Handler.
+func (h *NoopHandler) SetCharacterProtection(protected bool) {}
+
 // ResetAttributes implements Handler.
 func (h *NoopHandler) ResetAttributes() {}
 
@@ -300,6 +463,9 @@ func (h *NoopHandler) SetForeground(color Color) {}
 // SetBackground implements Handler.
 func (h *NoopHandler) SetBackground(color Color) {}
 
+// SetUnderlineColor implements Handler.
+func (h *NoopHandler) SetUnderlineColor(color Color) {}
+
 // ResetColors implements Handler.
 func (h *NoopHandler) ResetColors() {}
 
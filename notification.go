@@ -0,0 +1,224 @@
+package govte
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// NotificationUrgency represents the urgency of a desktop notification.
+type NotificationUrgency uint8
+
+const (
+	NotificationUrgencyLow NotificationUrgency = iota
+	NotificationUrgencyNormal
+	NotificationUrgencyCritical
+)
+
+// String returns the string representation of NotificationUrgency.
+func (u NotificationUrgency) String() string {
+	switch u {
+	case NotificationUrgencyLow:
+		return "Low"
+	case NotificationUrgencyNormal:
+		return "Normal"
+	case NotificationUrgencyCritical:
+		return "Critical"
+	default:
+		return "Unknown"
+	}
+}
+
+// Notification represents a desktop notification requested by the application.
+// It unifies the OSC 9, OSC 777 and kitty OSC 99 notification dialects.
+type Notification struct {
+	ID      string // Identifier supplied by the application (OSC 99 only)
+	Title   string
+	Body    string
+	Urgency NotificationUrgency
+}
+
+// maxPendingNotifications is the number of partially received notifications
+// the decoder keeps; starting another one drops the oldest.
+const maxPendingNotifications = 16
+
+// NotificationDecoder assembles notifications from OSC sequences.
+// Kitty notifications (OSC 99) may be split into several chunks, so the
+// decoder keeps partially received notifications between calls.
+// The zero value is ready to use.
+type NotificationDecoder struct {
+	pending map[string]*Notification
+	order   []string // pending ids, oldest first
+}
+
+// Decode processes the parameters of an OSC sequence.
+// It returns the notification and true when the sequence completes one.
+func (d *NotificationDecoder) Decode(params [][]byte) (Notification, bool) {
+	if len(params) == 0 {
+		return Notification{}, false
+	}
+
+	switch string(params[0]) {
+	case "9":
+		// OSC 9 ; message - iTerm2/ConEmu style notification
+		if len(params) < 2 || isConEmuCommand(params[1:]) {
+			return Notification{}, false
+		}
+		return Notification{
+			Body:    string(joinOSCParams(params[1:])),
+			Urgency: NotificationUrgencyNormal,
+		}, true
+
+	case "777":
+		// OSC 777 ; notify ; title ; body - rxvt-unicode style notification
+		if len(params) < 3 || string(params[1]) != "notify" {
+			return Notification{}, false
+		}
+		n := Notification{
+			Title:   string(params[2]),
+			Urgency: NotificationUrgencyNormal,
+		}
+		if len(params) > 3 {
+			n.Body = string(joinOSCParams(params[3:]))
+		}
+		return n, true
+
+	case "99":
+		// OSC 99 ; metadata ; payload - kitty desktop notification
+		if len(params) < 2 {
+			return Notification{}, false
+		}
+		var payload []byte
+		if len(params) > 2 {
+			payload = joinOSCParams(params[2:])
+		}
+		return d.decodeKitty(params[1], payload)
+	}
+
+	return Notification{}, false
+}
+
+// Reset discards any partially received notifications.
+func (d *NotificationDecoder) Reset() {
+	d.pending = nil
+	d.order = nil
+}
+
+// decodeKitty handles one chunk of a kitty notification.
+func (d *NotificationDecoder) decodeKitty(metadata, payload []byte) (Notification, bool) {
+	id := ""
+	done := true
+	payloadType := "title"
+	encoded := false
+	urgency := -1
+
+	for _, field := range strings.Split(string(metadata), ":") {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "i":
+			id = value
+		case "d":
+			done = value != "0"
+		case "p":
+			payloadType = value
+		case "e":
+			encoded = value == "1"
+		case "u":
+			switch value {
+			case "0":
+				urgency = int(NotificationUrgencyLow)
+			case "1":
+				urgency = int(NotificationUrgencyNormal)
+			case "2":
+				urgency = int(NotificationUrgencyCritical)
+			}
+		}
+	}
+
+	if encoded {
+		decoded, err := base64.StdEncoding.DecodeString(string(payload))
+		if err != nil {
+			return Notification{}, false
+		}
+		payload = decoded
+	}
+
+	if d.pending == nil {
+		d.pending = make(map[string]*Notification)
+	}
+	n, exists := d.pending[id]
+
+	switch payloadType {
+	case "title", "body":
+		if !exists {
+			if len(d.order) >= maxPendingNotifications {
+				d.remove(d.order[0])
+			}
+			n = &Notification{ID: id, Urgency: NotificationUrgencyNormal}
+			d.pending[id] = n
+			d.order = append(d.order, id)
+		}
+		if payloadType == "title" {
+			n.Title += string(payload)
+		} else {
+			n.Body += string(payload)
+		}
+	default:
+		// Icons, buttons and queries carry nothing we model, but they may
+		// still finish a notification started by earlier chunks
+		if !exists {
+			return Notification{}, false
+		}
+	}
+
+	if urgency >= 0 {
+		n.Urgency = NotificationUrgency(urgency) //nolint:gosec // urgency is one of the constants above
+	}
+
+	if !done {
+		return Notification{}, false
+	}
+
+	d.remove(id)
+	return *n, true
+}
+
+// remove discards a pending notification.
+func (d *NotificationDecoder) remove(id string) {
+	delete(d.pending, id)
+	for i, pendingID := range d.order {
+		if pendingID == id {
+			d.order = append(d.order[:i], d.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// isConEmuCommand reports whether OSC 9 parameters form a ConEmu
+// sub-command (e.g. "9;4;1;50" for progress or "9;9;path" for the
+// working directory) rather than a message.
+func isConEmuCommand(params [][]byte) bool {
+	switch string(params[0]) {
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12":
+		return true
+	}
+	return false
+}
+
+// joinOSCParams rejoins OSC parameters that were split on ';' so that
+// free-form text containing semicolons survives intact.
+func joinOSCParams(params [][]byte) []byte {
+	if len(params) == 1 {
+		return params[0]
+	}
+	var result []byte
+	for i, param := range params {
+		if i > 0 {
+			result = append(result, ';')
+		}
+		result = append(result, param...)
+	}
+	return result
+}
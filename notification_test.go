@@ -0,0 +1,139 @@
+package govte
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NotifyHandler is a test handler that records notifications
+type NotifyHandler struct {
+	NoopHandler
+	notifications []Notification
+}
+
+// Notify implements Handler
+func (h *NotifyHandler) Notify(notification Notification) {
+	h.notifications = append(h.notifications, notification)
+}
+
+func TestNotificationUrgencyString(t *testing.T) {
+	assert.Equal(t, "Low", NotificationUrgencyLow.String())
+	assert.Equal(t, "Normal", NotificationUrgencyNormal.String())
+	assert.Equal(t, "Critical", NotificationUrgencyCritical.String())
+	assert.Equal(t, "Unknown", NotificationUrgency(9).String())
+}
+
+func TestProcessorNotifications(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence string
+		expected []Notification
+	}{
+		{
+			name:     "OSC 9 message",
+			sequence: "\x1b]9;build finished\x07",
+			expected: []Notification{{Body: "build finished", Urgency: NotificationUrgencyNormal}},
+		},
+		{
+			name:     "OSC 9 message with semicolons",
+			sequence: "\x1b]9;done; 3 warnings\x1b\\",
+			expected: []Notification{{Body: "done; 3 warnings", Urgency: NotificationUrgencyNormal}},
+		},
+		{
+			name:     "OSC 9 ConEmu sub-command is not a notification",
+			sequence: "\x1b]9;4;1;50\x07",
+			expected: nil,
+		},
+		{
+			name:     "OSC 777 notify",
+			sequence: "\x1b]777;notify;make;build finished\x07",
+			expected: []Notification{{Title: "make", Body: "build finished", Urgency: NotificationUrgencyNormal}},
+		},
+		{
+			name:     "OSC 777 notify with empty title",
+			sequence: "\x1b]777;notify;;body only\x07",
+			expected: []Notification{{Body: "body only", Urgency: NotificationUrgencyNormal}},
+		},
+		{
+			name:     "OSC 777 other command",
+			sequence: "\x1b]777;preexec\x07",
+			expected: nil,
+		},
+		{
+			name:     "OSC 99 simple title",
+			sequence: "\x1b]99;;Hello world\x1b\\",
+			expected: []Notification{{Title: "Hello world", Urgency: NotificationUrgencyNormal}},
+		},
+		{
+			name:     "OSC 99 chunked title and body",
+			sequence: "\x1b]99;i=1:d=0:u=2;Build\x1b\\\x1b]99;i=1:d=0:p=body;All 42 tests\x1b\\\x1b]99;i=1:p=body; passed\x1b\\",
+			expected: []Notification{{ID: "1", Title: "Build", Body: "All 42 tests passed", Urgency: NotificationUrgencyCritical}},
+		},
+		{
+			name:     "OSC 99 base64 payload",
+			sequence: "\x1b]99;i=x:e=1:u=0;aGVsbG8=\x1b\\",
+			expected: []Notification{{ID: "x", Title: "hello", Urgency: NotificationUrgencyLow}},
+		},
+		{
+			name:     "OSC 99 query is ignored",
+			sequence: "\x1b]99;i=q:p=?;\x1b\\",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &NotifyHandler{}
+			p := NewProcessor(h)
+
+			p.Advance(h, []byte(tt.sequence))
+			assert.Equal(t, tt.expected, h.notifications)
+		})
+	}
+}
+
+func TestNotificationDecoderReset(t *testing.T) {
+	var d NotificationDecoder
+
+	_, ok := d.Decode([][]byte{[]byte("99"), []byte("i=1:d=0"), []byte("partial")})
+	assert.False(t, ok)
+
+	d.Reset()
+
+	n, ok := d.Decode([][]byte{[]byte("99"), []byte("i=1"), []byte("fresh")})
+	assert.True(t, ok)
+	assert.Equal(t, "fresh", n.Title)
+}
+
+func TestNotificationDecoderPendingLimit(t *testing.T) {
+	var d NotificationDecoder
+
+	for i := 0; i < maxPendingNotifications+5; i++ {
+		metadata := fmt.Sprintf("i=%d:d=0", i)
+		_, ok := d.Decode([][]byte{[]byte("99"), []byte(metadata), []byte("part")})
+		assert.False(t, ok)
+	}
+	assert.Len(t, d.pending, maxPendingNotifications)
+	assert.Len(t, d.order, maxPendingNotifications)
+
+	// The oldest notifications were dropped; the newest can still finish
+	_, ok := d.Decode([][]byte{[]byte("99"), []byte("i=0:p=icon")})
+	assert.False(t, ok)
+
+	last := fmt.Sprintf("i=%d:p=icon", maxPendingNotifications+4)
+	n, ok := d.Decode([][]byte{[]byte("99"), []byte(last)})
+	assert.True(t, ok)
+	assert.Equal(t, "part", n.Title)
+	assert.Len(t, d.order, maxPendingNotifications-1)
+}
+
+func TestProcessorNotificationsRIS(t *testing.T) {
+	h := &NotifyHandler{}
+	p := NewProcessor(h)
+
+	// RIS discards partially received notifications
+	p.Advance(h, []byte("\x1b]99;i=1:d=0;partial\x1b\\\x1bc\x1b]99;i=1:p=icon;\x1b\\"))
+	assert.Empty(t, h.notifications)
+}
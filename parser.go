@@ -677,15 +677,17 @@ func (p *Parser) oscDispatch(performer Performer, bellTerminated bool) {
 	params := make([][]byte, 0, p.oscNumParams+1)
 	start := 0
 
+	// Empty parameters are kept so that positional fields such as the
+	// title in "777;notify;;body" stay in place
 	for _, end := range p.oscParams {
-		if end > start && end <= len(p.oscRaw) {
+		if end >= start && end <= len(p.oscRaw) {
 			params = append(params, p.oscRaw[start:end])
 			start = end
 		}
 	}
 
 	// Add final parameter
-	if start < len(p.oscRaw) {
+	if start < len(p.oscRaw) || p.oscNumParams > 0 {
 		params = append(params, p.oscRaw[start:])
 	}
 
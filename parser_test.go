@@ -567,3 +567,24 @@ func TestParserAdditionalStateTransitions(t *testing.T) {
 		assert.Equal(t, StateGround, parser.State())
 	})
 }
+
+func TestParserOSCEmptyParams(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected [][]byte
+	}{
+		{"\x1b]777;notify;;body\x07", [][]byte{[]byte("777"), []byte("notify"), []byte(""), []byte("body")}},
+		{"\x1b]8;;\x07", [][]byte{[]byte("8"), []byte(""), []byte("")}},
+		{"\x1b]\x07", [][]byte{}},
+	}
+
+	for _, tt := range tests {
+		parser := NewParser()
+		performer := &MockPerformer{}
+
+		parser.Advance(performer, []byte(tt.input))
+
+		assert.Len(t, performer.oscDispatched, 1)
+		assert.Equal(t, tt.expected, performer.oscDispatched[0].params)
+	}
+}
@@ -1,6 +1,7 @@
 package govte
 
 import (
+	"fmt"
 	"io"
 	"time"
 )
@@ -28,14 +29,35 @@ type Processor struct {
 	syncState *SyncState
 	dcsState  *DCSState
 	modes     map[Mode]bool
+
+	// Modes DECRQM reports, modes the application cannot change, and the
+	// modes saved by XTSAVE
+	recognizedModes map[Mode]bool
+	permanentModes  map[Mode]bool
+	savedModes      map[Mode]bool
+
+	// cursorStyle tracks the cursor appearance so that the xterm blink
+	// mode can be combined with the shape selected by DECSCUSR
+	cursorStyle CursorStyle
+
+	// notifications assembles desktop notifications across OSC sequences
+	notifications NotificationDecoder
+
+	// Text area size in characters and character cell size in pixels
+	columns, lines        int
+	cellWidth, cellHeight int
+
+	// Window operations (CSI Ps t) the application may use
+	windowOps map[WindowOp]bool
 }
 
 // NewProcessor creates a new Processor with a handler.
 func NewProcessor(handler Handler) *Processor {
-	return &Processor{
-		parser:  NewParser(),
-		handler: handler,
-		modes:   make(map[Mode]bool),
+	p := &Processor{
+		parser:          NewParser(),
+		handler:         handler,
+		recognizedModes: make(map[Mode]bool),
+		permanentModes:  make(map[Mode]bool),
 		syncState: &SyncState{
 			timeout: 150 * time.Millisecond, // Default timeout
 		},
@@ -43,7 +65,18 @@ func NewProcessor(handler Handler) *Processor {
 			active: false,
 			buffer: make([]byte, 0),
 		},
+		columns:   80,
+		lines:     24,
+		windowOps: make(map[WindowOp]bool),
+	}
+	for _, op := range DefaultWindowOps {
+		p.windowOps[op] = true
+	}
+	for mode := range recognizedModes {
+		p.recognizedModes[mode] = true
 	}
+	p.resetModes()
+	return p
 }
 
 // NewProcessorWithBuffer creates a new Processor with a buffer and handler.
@@ -130,6 +163,78 @@ func (p *Processor) IsMode(mode Mode) bool {
 	return p.modes[mode]
 }
 
+// Resize sets the text area size in characters. It is 80x24 until the
+// embedder reports the real size; non-positive dimensions are ignored.
+func (p *Processor) Resize(columns, lines int) {
+	if columns > 0 && lines > 0 {
+		p.columns, p.lines = columns, lines
+	}
+}
+
+// Size returns the text area size in characters.
+func (p *Processor) Size() (columns, lines int) {
+	return p.columns, p.lines
+}
+
+// SetCellSize sets the character cell size in pixels. The pixel size
+// reports (CSI 14 t and CSI 16 t) are not answered while it is unknown.
+func (p *Processor) SetCellSize(width, height int) {
+	p.cellWidth, p.cellHeight = width, height
+}
+
+// AllowWindowOp allows or forbids a window operation (XTWINOPS).
+func (p *Processor) AllowWindowOp(op WindowOp, allowed bool) {
+	p.windowOps[op] = allowed
+}
+
+// IsWindowOpAllowed returns true if the application may use the window operation.
+func (p *Processor) IsWindowOpAllowed(op WindowOp) bool {
+	return p.windowOps[op]
+}
+
+// SetPermanentMode marks a mode as permanently set or reset: the application
+// cannot change it, and DECRQM reports it as permanent.
+func (p *Processor) SetPermanentMode(mode Mode, enabled bool) {
+	p.permanentModes[mode] = enabled
+	p.SetMode(mode, enabled)
+}
+
+// SetModeRecognized sets whether DECRQM reports a mode as recognized; modes
+// the embedder does not support can be reported as unknown.
+func (p *Processor) SetModeRecognized(mode Mode, recognized bool) {
+	p.recognizedModes[mode] = recognized
+}
+
+// resetModes returns the modes to their initial state and forgets the modes
+// saved by XTSAVE. Permanent modes keep their state.
+func (p *Processor) resetModes() {
+	p.modes = map[Mode]bool{
+		ModeAutoWrap:   true,
+		ModeShowCursor: true,
+	}
+	for mode, enabled := range p.permanentModes {
+		p.modes[mode] = enabled
+	}
+	p.savedModes = make(map[Mode]bool)
+}
+
+// QueryMode returns the state of a mode as reported by DECRQM.
+func (p *Processor) QueryMode(mode Mode) ModeState {
+	if enabled, ok := p.permanentModes[mode]; ok {
+		if enabled {
+			return ModeStatePermanentlySet
+		}
+		return ModeStatePermanentlyReset
+	}
+	if !p.recognizedModes[mode] {
+		return ModeStateNotRecognized
+	}
+	if p.IsMode(mode) {
+		return ModeStateSet
+	}
+	return ModeStateReset
+}
+
 // Write writes data to the processor (for buffered output).
 func (p *Processor) Write(data string) {
 	if p.syncState.enabled {
@@ -141,6 +246,14 @@ func (p *Processor) Write(data string) {
 	}
 }
 
+// reply sends a report to the application. Unlike Write it never goes
+// into the synchronized update buffer, which holds input.
+func (p *Processor) reply(data string) {
+	if p.output != nil {
+		_, _ = io.WriteString(p.output, data)
+	}
+}
+
 // Process processes raw bytes through the parser.
 func (p *Processor) Process(data []byte) {
 	if p.handler != nil {
@@ -156,6 +269,15 @@ func (p *Processor) Reset() {
 	p.syncState.buffer = p.syncState.buffer[:0]
 	p.dcsState.active = false
 	p.dcsState.buffer = p.dcsState.buffer[:0]
+	p.resetState()
+}
+
+// resetState returns the terminal state tracked by the processor to its
+// initial values, for Reset and RIS.
+func (p *Processor) resetState() {
+	p.notifications.Reset()
+	p.cursorStyle = CursorStyle{}
+	p.resetModes()
 }
 
 // processorPerformer implements Performer and translates to Handler calls.
@@ -250,11 +372,36 @@ func (pp *processorPerformer) OscDispatch(params [][]byte, bellTerminated bool)
 	}
 
 	switch oscNum {
-	case 0, 2:
+	case 0:
+		// Set icon name and window title
+		if len(params) > 1 {
+			pp.handler.SetIconName(string(params[1]))
+			pp.handler.SetTitle(string(params[1]))
+		}
+
+	case 1:
+		// Set icon name
+		if len(params) > 1 {
+			pp.handler.SetIconName(string(params[1]))
+		}
+
+	case 2:
 		// Set window title
 		if len(params) > 1 {
 			pp.handler.SetTitle(string(params[1]))
 		}
+
+	case 9, 99, 777:
+		// Progress reports share OSC 9 with notifications
+		if state, percent, ok := ParseProgress(params); ok {
+			pp.handler.SetProgress(state, percent)
+			return
+		}
+
+		// Desktop notifications
+		if notification, ok := pp.processor.notifications.Decode(params); ok {
+			pp.handler.Notify(notification)
+		}
 	}
 }
 
@@ -267,6 +414,13 @@ func (pp *processorPerformer) CsiDispatch(params *Params, intermediates []byte,
 	// Get parameter groups
 	groups := params.Iter()
 
+	if pp.rectangularArea(groups, intermediates, action) {
+		return
+	}
+
+	// '?' selects the DEC private modes
+	private := len(intermediates) > 0 && intermediates[0] == '?'
+
 	switch action {
 	case 'A':
 		// CUU - Cursor Up
@@ -310,14 +464,22 @@ func (pp *processorPerformer) CsiDispatch(params *Params, intermediates []byte,
 		pp.handler.Goto(row, col)
 
 	case 'J':
-		// ED - Erase Display
+		// ED - Erase Display, DECSED - Selective Erase in Display
 		mode := getParam(groups, 0, 0, 0)
-		pp.handler.ClearScreen(ClearMode(mode)) //nolint:gosec // mode is validated by getParam
+		if len(intermediates) > 0 && intermediates[0] == '?' {
+			pp.handler.SelectiveClearScreen(ClearMode(mode)) //nolint:gosec // mode is validated by getParam
+		} else {
+			pp.handler.ClearScreen(ClearMode(mode)) //nolint:gosec // mode is validated by getParam
+		}
 
 	case 'K':
-		// EL - Erase Line
+		// EL - Erase Line, DECSEL - Selective Erase in Line
 		mode := getParam(groups, 0, 0, 0)
-		pp.handler.ClearLine(LineClearMode(mode)) //nolint:gosec // mode is validated by getParam
+		if len(intermediates) > 0 && intermediates[0] == '?' {
+			pp.handler.SelectiveClearLine(LineClearMode(mode)) //nolint:gosec // mode is validated by getParam
+		} else {
+			pp.handler.ClearLine(LineClearMode(mode)) //nolint:gosec // mode is validated by getParam
+		}
 
 	case 'L':
 		// IL - Insert Lines
@@ -364,57 +526,58 @@ func (pp *processorPerformer) CsiDispatch(params *Params, intermediates []byte,
 		pp.processSGR(groups)
 
 	case 'r':
-		// DECSTBM - Set Scrolling Region
-		top := getParam(groups, 0, 0, 1)
-		bottom := getParam(groups, 1, 0, 0)
-		if bottom == 0 {
-			// 0 means default (bottom of screen)
-			bottom = 24 // Default terminal height, should be configurable
+		if private {
+			// XTRESTORE - Restore DEC private modes
+			pp.restoreModes(groups)
+		} else {
+			// DECSTBM - Set Scrolling Region
+			top := getParam(groups, 0, 0, 1)
+			bottom := getParam(groups, 1, 0, 0)
+			if bottom == 0 {
+				// 0 means default (bottom of screen)
+				bottom = pp.processor.lines
+			}
+			pp.handler.SetScrollingRegion(top, bottom)
 		}
-		pp.handler.SetScrollingRegion(top, bottom)
 
 	case 's':
-		// Save cursor position
-		pp.handler.SaveCursorPosition()
+		if private {
+			// XTSAVE - Save DEC private modes
+			pp.saveModes(groups)
+		} else if pp.processor.IsMode(ModeLeftRightMargin) {
+			// DECSLRM - Set Left and Right Margins, only while DECLRMM is set
+			pp.handler.SetLeftRightMargins(getParam(groups, 0, 0, 1), getParam(groups, 1, 0, 0))
+		} else {
+			// Save cursor position
+			pp.handler.SaveCursorPosition()
+		}
 
 	case 'u':
 		// Restore cursor position
 		pp.handler.RestoreCursorPosition()
 
 	case 'h':
-		// SM - Set Mode
-		if len(intermediates) > 0 && intermediates[0] == '?' {
-			// Private mode
-			for _, group := range groups {
-				if len(group) > 0 {
-					pp.handler.SetMode(Mode(0x200 + group[0]))
-				}
-			}
-		} else {
-			// Standard mode
-			for _, group := range groups {
-				if len(group) > 0 {
-					pp.handler.SetMode(Mode(group[0]))
-				}
-			}
-		}
+		// SM - Set Mode, DECSET with '?'
+		pp.setModes(groups, private, true)
 
 	case 'l':
-		// RM - Reset Mode
-		if len(intermediates) > 0 && intermediates[0] == '?' {
-			// Private mode
-			for _, group := range groups {
-				if len(group) > 0 {
-					pp.handler.ResetMode(Mode(0x200 + group[0]))
-				}
-			}
-		} else {
-			// Standard mode
-			for _, group := range groups {
-				if len(group) > 0 {
-					pp.handler.ResetMode(Mode(group[0]))
-				}
-			}
+		// RM - Reset Mode, DECRST with '?'
+		pp.setModes(groups, private, false)
+
+	case 'p':
+		// DECRQM - Request Mode
+		if len(intermediates) > 0 && intermediates[len(intermediates)-1] == '$' {
+			pp.reportMode(getParam(groups, 0, 0, 0), private)
+		}
+
+	case 'q':
+		// DECSCUSR - Set Cursor Style
+		if len(intermediates) == 1 && intermediates[0] == ' ' {
+			pp.setCursorStyle(getParam(groups, 0, 0, 0))
+		}
+		// DECSCA - Select Character Protection Attribute
+		if len(intermediates) == 1 && intermediates[0] == '"' {
+			pp.handler.SetCharacterProtection(getParam(groups, 0, 0, 0) == 1)
 		}
 
 	case 'n':
@@ -445,15 +608,93 @@ func (pp *processorPerformer) CsiDispatch(params *Params, intermediates []byte,
 		// CBT - Cursor Backward Tab
 		count := getParam(groups, 0, 0, 1)
 		pp.handler.TabBackward(count)
+
+	case '|':
+		// DECSCPP - Set Columns Per Page
+		if len(intermediates) == 1 && intermediates[0] == '$' {
+			pp.handler.SetColumnsPerPage(pageColumns(getParam(groups, 0, 0, 0)))
+		}
+
+	case 't':
+		if len(intermediates) == 0 {
+			pp.windowOperation(groups)
+		}
 	}
 }
 
+// windowOperation handles the window operations of CSI Ps t (XTWINOPS)
+// that the processor allows; values of 24 and above are DECSLPP
+func (pp *processorPerformer) windowOperation(groups [][]uint16) {
+	p := pp.processor
+	op := getParam(groups, 0, 0, 0)
+	if op >= int(WindowOpResizeLines) {
+		// DECSLPP - Set Lines Per Page
+		if p.IsWindowOpAllowed(WindowOpResizeLines) {
+			pp.handler.SetLinesPerPage(op)
+		}
+		return
+	}
+	if !p.IsWindowOpAllowed(WindowOp(op)) {
+		return
+	}
+
+	switch WindowOp(op) {
+	case WindowOpDeiconify:
+		pp.handler.SetIconified(false)
+	case WindowOpIconify:
+		pp.handler.SetIconified(true)
+	case WindowOpResize:
+		// Omitted or zero dimensions keep the current size
+		lines := getParam(groups, 1, 0, p.lines)
+		columns := getParam(groups, 2, 0, p.columns)
+		pp.handler.ResizeTextArea(columns, lines)
+	case WindowOpReportPixelSize:
+		if p.cellWidth > 0 && p.cellHeight > 0 {
+			p.reply(fmt.Sprintf("\x1b[4;%d;%dt", p.lines*p.cellHeight, p.columns*p.cellWidth))
+		}
+	case WindowOpReportCellSize:
+		if p.cellWidth > 0 && p.cellHeight > 0 {
+			p.reply(fmt.Sprintf("\x1b[6;%d;%dt", p.cellHeight, p.cellWidth))
+		}
+	case WindowOpReportTextAreaSize:
+		p.reply(fmt.Sprintf("\x1b[8;%d;%dt", p.lines, p.columns))
+	case WindowOpReportIconName:
+		pp.handler.ReportTitle(TitleIconName)
+	case WindowOpReportTitle:
+		pp.handler.ReportTitle(TitleWindow)
+	case WindowOpPushTitle:
+		pp.handler.PushTitle(ParseTitleTarget(getParam(groups, 1, 0, 0)))
+	case WindowOpPopTitle:
+		pp.handler.PopTitle(ParseTitleTarget(getParam(groups, 1, 0, 0)))
+	}
+}
+
+// pageColumns returns the page width selected by DECSCPP: 132 for values
+// above 80, otherwise 80.
+func pageColumns(columns int) int {
+	if columns > 80 {
+		return 132
+	}
+	return 80
+}
+
 // EscDispatch implements Performer.
 func (pp *processorPerformer) EscDispatch(intermediates []byte, ignore bool, b byte) {
 	if ignore {
 		return
 	}
 
+	if len(intermediates) == 1 && intermediates[0] == '#' {
+		pp.lineSize(b)
+		return
+	}
+
+	if len(intermediates) > 0 {
+		// SCS - Select Character Set
+		pp.configureCharset(intermediates, b)
+		return
+	}
+
 	switch b {
 	case '7':
 		// DECSC - Save Cursor
@@ -465,6 +706,7 @@ func (pp *processorPerformer) EscDispatch(intermediates []byte, ignore bool, b b
 
 	case 'c':
 		// RIS - Reset to Initial State
+		pp.processor.resetState()
 		pp.handler.Reset()
 
 	case 'D':
@@ -479,41 +721,218 @@ func (pp *processorPerformer) EscDispatch(intermediates []byte, ignore bool, b b
 		// RI - Reverse Index (move up one line)
 		pp.handler.MoveUp(1)
 
-	case 'B':
-		// Configure charset to ASCII
-		pp.configureCharset(intermediates, StandardCharsetASCII)
-
-	case '0':
-		// Configure charset to special line drawing
-		pp.configureCharset(intermediates, StandardCharsetSpecialLineDrawing)
-
 	case 'H':
 		// HTS - Horizontal Tab Set
 		pp.handler.SetTabStop()
+
+	case 'V':
+		// SPA - Start of Protected Area
+		pp.handler.SetCharacterProtection(true)
+
+	case 'W':
+		// EPA - End of Protected Area
+		pp.handler.SetCharacterProtection(false)
 	}
 }
 
-// configureCharset configures a character set based on intermediate bytes.
-func (pp *processorPerformer) configureCharset(intermediates []byte, charset StandardCharset) {
-	if len(intermediates) != 1 {
+// setCursorStyle handles DECSCUSR (CSI Ps SP q).
+func (pp *processorPerformer) setCursorStyle(style int) {
+	var cursorStyle CursorStyle
+	switch style {
+	case 0, 1:
+		cursorStyle = CursorStyle{Shape: CursorShapeBlock, Blinking: true}
+	case 2:
+		cursorStyle = CursorStyle{Shape: CursorShapeBlock}
+	case 3:
+		cursorStyle = CursorStyle{Shape: CursorShapeUnderline, Blinking: true}
+	case 4:
+		cursorStyle = CursorStyle{Shape: CursorShapeUnderline}
+	case 5:
+		cursorStyle = CursorStyle{Shape: CursorShapeBeam, Blinking: true}
+	case 6:
+		cursorStyle = CursorStyle{Shape: CursorShapeBeam}
+	default:
 		return
 	}
 
-	var index CharsetIndex
-	switch intermediates[0] {
-	case '(':
-		index = G0
-	case ')':
-		index = G1
-	case '*':
-		index = G2
-	case '+':
-		index = G3
-	default:
+	pp.processor.cursorStyle = cursorStyle
+	pp.handler.SetCursorStyle(cursorStyle)
+}
+
+// setModes handles SM and RM, or DECSET and DECRST for private modes
+func (pp *processorPerformer) setModes(groups [][]uint16, private, enabled bool) {
+	for _, group := range groups {
+		if len(group) > 0 {
+			pp.setMode(ModeFromParam(group[0], private), enabled)
+		}
+	}
+}
+
+// setMode sets or resets a mode and tracks its state; permanent modes are
+// left alone
+func (pp *processorPerformer) setMode(mode Mode, enabled bool) {
+	if _, permanent := pp.processor.permanentModes[mode]; permanent {
 		return
 	}
 
-	pp.handler.ConfigureCharset(index, charset)
+	if enabled {
+		pp.handler.SetMode(mode)
+	} else {
+		pp.handler.ResetMode(mode)
+	}
+	pp.processor.SetMode(mode, enabled)
+	pp.setCursorMode(mode, enabled)
+}
+
+// reportMode answers DECRQM with DECRPM: CSI ? Ps ; Pm $ y for private modes,
+// CSI Ps ; Pm $ y for ANSI modes
+func (pp *processorPerformer) reportMode(param int, private bool) {
+	state := pp.processor.QueryMode(ModeFromParam(uint16(param), private)) //nolint:gosec // param comes from a uint16
+	if private {
+		pp.processor.reply(fmt.Sprintf("\x1b[?%d;%d$y", param, state))
+	} else {
+		pp.processor.reply(fmt.Sprintf("\x1b[%d;%d$y", param, state))
+	}
+}
+
+// saveModes handles XTSAVE, saving the state of DEC private modes
+func (pp *processorPerformer) saveModes(groups [][]uint16) {
+	for _, group := range groups {
+		if len(group) > 0 {
+			mode := ModeFromParam(group[0], true)
+			pp.processor.savedModes[mode] = pp.processor.IsMode(mode)
+		}
+	}
+}
+
+// restoreModes handles XTRESTORE, restoring DEC private modes saved by XTSAVE
+func (pp *processorPerformer) restoreModes(groups [][]uint16) {
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		mode := ModeFromParam(group[0], true)
+		if enabled, ok := pp.processor.savedModes[mode]; ok {
+			pp.setMode(mode, enabled)
+		}
+	}
+}
+
+// setCursorMode dispatches the private modes that change the cursor
+// appearance: DECTCEM (?25) and the xterm blinking cursor mode (?12).
+func (pp *processorPerformer) setCursorMode(mode Mode, enabled bool) {
+	switch mode {
+	case ModeShowCursor:
+		pp.handler.SetCursorVisible(enabled)
+	case ModeBlinkingCursor:
+		pp.processor.cursorStyle.Blinking = enabled
+		pp.handler.SetCursorStyle(pp.processor.cursorStyle)
+	}
+}
+
+// rectangularArea dispatches the VT420 rectangular area operations and
+// DECSACE. It reports whether the sequence was one of them.
+func (pp *processorPerformer) rectangularArea(groups [][]uint16, intermediates []byte, action rune) bool {
+	if len(intermediates) != 1 {
+		return false
+	}
+
+	switch {
+	case intermediates[0] == '$' && action == 'x':
+		// DECFRA - Fill Rectangular Area
+		c := getParam(groups, 0, 0, 0)
+		if (c >= 0x20 && c <= 0x7E) || (c >= 0xA0 && c <= 0xFF) {
+			pp.handler.FillRectangle(rune(c), rectangleParams(groups, 1)) //nolint:gosec // c is a validated character code
+		}
+
+	case intermediates[0] == '$' && action == 'z':
+		// DECERA - Erase Rectangular Area
+		pp.handler.EraseRectangle(rectangleParams(groups, 0))
+
+	case intermediates[0] == '$' && action == '{':
+		// DECSERA - Selective Erase Rectangular Area
+		pp.handler.SelectiveEraseRectangle(rectangleParams(groups, 0))
+
+	case intermediates[0] == '$' && action == 'v':
+		// DECCRA - Copy Rectangular Area; the page numbers are ignored
+		top := getParam(groups, 5, 0, 1)
+		left := getParam(groups, 6, 0, 1)
+		pp.handler.CopyRectangle(rectangleParams(groups, 0), top, left)
+
+	case intermediates[0] == '$' && action == 'r':
+		// DECCARA - Change Attributes in Rectangular Area
+		pp.handler.ChangeRectangleAttributes(rectangleParams(groups, 0), attributeParams(groups, 4))
+
+	case intermediates[0] == '$' && action == 't':
+		// DECRARA - Reverse Attributes in Rectangular Area
+		pp.handler.ReverseRectangleAttributes(rectangleParams(groups, 0), attributeParams(groups, 4))
+
+	case intermediates[0] == '*' && action == 'x':
+		// DECSACE - Select Attribute Change Extent
+		if getParam(groups, 0, 0, 0) == 2 {
+			pp.handler.SetAttributeChangeExtent(AttributeChangeRectangle)
+		} else {
+			pp.handler.SetAttributeChangeExtent(AttributeChangeStream)
+		}
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// rectangleParams reads the top, left, bottom and right parameters of a
+// rectangular area operation starting at group start.
+func rectangleParams(groups [][]uint16, start int) Rectangle {
+	return Rectangle{
+		Top:    getParam(groups, start, 0, 1),
+		Left:   getParam(groups, start+1, 0, 1),
+		Bottom: getParam(groups, start+2, 0, 0),
+		Right:  getParam(groups, start+3, 0, 0),
+	}
+}
+
+// attributeParams reads the SGR attributes of DECCARA and DECRARA starting
+// at group start; no attributes means 0.
+func attributeParams(groups [][]uint16, start int) []int {
+	attrs := []int{}
+	for i := start; i < len(groups); i++ {
+		attrs = append(attrs, getParam(groups, i, 0, 0))
+	}
+	if len(attrs) == 0 {
+		attrs = append(attrs, 0)
+	}
+	return attrs
+}
+
+// configureCharset configures a character set based on the intermediate
+// bytes and final character of an SCS sequence.
+func (pp *processorPerformer) configureCharset(intermediates []byte, final byte) {
+	if index, charset, ok := ParseCharsetDesignation(intermediates, final); ok {
+		pp.handler.ConfigureCharset(index, charset)
+	}
+}
+
+// lineSize dispatches ESC # Pn: the line sizes and DECALN
+func (pp *processorPerformer) lineSize(final byte) {
+	switch final {
+	case '3':
+		// DECDHL - Double-Height Line, top half
+		pp.handler.SetLineSize(LineSizeDoubleHeightTop)
+	case '4':
+		// DECDHL - Double-Height Line, bottom half
+		pp.handler.SetLineSize(LineSizeDoubleHeightBottom)
+	case '5':
+		// DECSWL - Single-Width Line
+		pp.handler.SetLineSize(LineSizeSingle)
+	case '6':
+		// DECDWL - Double-Width Line
+		pp.handler.SetLineSize(LineSizeDoubleWidth)
+	case '8':
+		// DECALN - Screen Alignment Pattern
+		pp.handler.ScreenAlignmentTest()
+	}
 }
 
 // processSGR processes SGR (Select Graphic Rendition) sequences.
@@ -525,7 +944,8 @@ func (pp *processorPerformer) processSGR(groups [][]uint16) {
 		return
 	}
 
-	for _, group := range groups {
+	for i := 0; i < len(groups); i++ {
+		group := groups[i]
 		if len(group) == 0 {
 			continue
 		}
@@ -543,8 +963,9 @@ func (pp *processorPerformer) processSGR(groups [][]uint16) {
 		case 3:
 			pp.handler.SetAttribute(AttrItalic)
 		case 4:
-			pp.handler.SetAttribute(AttrUnderline)
-		case 5:
+			// Underline, with an optional style subparameter (4:0 - 4:5)
+			pp.processUnderline(group)
+		case 5, 6:
 			pp.handler.SetAttribute(AttrBlinking)
 		case 7:
 			pp.handler.SetAttribute(AttrReverse)
@@ -553,18 +974,43 @@ func (pp *processorPerformer) processSGR(groups [][]uint16) {
 		case 9:
 			pp.handler.SetAttribute(AttrStrikethrough)
 
+		case 10, 11, 12, 13, 14, 15, 16, 17, 18, 19:
+			// Primary (10) and alternate fonts (11-19)
+			pp.handler.SetFont(int(group[0]) - 10)
+
+		case 20:
+			pp.handler.SetAttribute(AttrFraktur)
+
 		case 21:
+			pp.handler.UnsetAttribute(AttrAnyUnderline)
 			pp.handler.SetAttribute(AttrDoubleUnderline)
 
+		case 22:
+			pp.handler.UnsetAttribute(AttrBold | AttrDim)
+		case 23:
+			pp.handler.UnsetAttribute(AttrItalic | AttrFraktur)
+		case 24:
+			pp.handler.UnsetAttribute(AttrAnyUnderline)
+		case 25:
+			pp.handler.UnsetAttribute(AttrBlinking)
+		case 27:
+			pp.handler.UnsetAttribute(AttrReverse)
+		case 28:
+			pp.handler.UnsetAttribute(AttrHidden)
+		case 29:
+			pp.handler.UnsetAttribute(AttrStrikethrough)
+
 		case 30, 31, 32, 33, 34, 35, 36, 37:
 			// Standard foreground colors
 			pp.handler.SetForeground(NewNamedColor(NamedColor(group[0] - 30))) //nolint:gosec // value is validated
 
 		case 38:
 			// Extended foreground color
-			if len(group) > 1 {
-				pp.processExtendedColor(group, true)
+			color, consumed, ok := parseExtendedColor(groups[i:])
+			if ok {
+				pp.handler.SetForeground(color)
 			}
+			i += consumed - 1
 
 		case 39:
 			// Default foreground
@@ -576,14 +1022,42 @@ func (pp *processorPerformer) processSGR(groups [][]uint16) {
 
 		case 48:
 			// Extended background color
-			if len(group) > 1 {
-				pp.processExtendedColor(group, false)
+			color, consumed, ok := parseExtendedColor(groups[i:])
+			if ok {
+				pp.handler.SetBackground(color)
 			}
+			i += consumed - 1
 
 		case 49:
 			// Default background
 			pp.handler.SetBackground(NewNamedColor(Background))
 
+		case 53:
+			pp.handler.SetAttribute(AttrOverline)
+		case 55:
+			pp.handler.UnsetAttribute(AttrOverline)
+
+		case 58:
+			// Underline color
+			color, consumed, ok := parseExtendedColor(groups[i:])
+			if ok {
+				pp.handler.SetUnderlineColor(color)
+			}
+			i += consumed - 1
+
+		case 59:
+			// Default underline color (follows the foreground)
+			pp.handler.SetUnderlineColor(NewNamedColor(Foreground))
+
+		case 73:
+			pp.handler.UnsetAttribute(AttrSubscript)
+			pp.handler.SetAttribute(AttrSuperscript)
+		case 74:
+			pp.handler.UnsetAttribute(AttrSuperscript)
+			pp.handler.SetAttribute(AttrSubscript)
+		case 75:
+			pp.handler.UnsetAttribute(AttrSuperscript | AttrSubscript)
+
 		case 90, 91, 92, 93, 94, 95, 96, 97:
 			// Bright foreground colors
 			pp.handler.SetForeground(NewNamedColor(NamedColor(group[0] - 90 + 8))) //nolint:gosec // value is validated
@@ -595,37 +1069,85 @@ func (pp *processorPerformer) processSGR(groups [][]uint16) {
 	}
 }
 
-// processExtendedColor processes extended color sequences (38/48).
-func (pp *processorPerformer) processExtendedColor(group []uint16, isForeground bool) {
-	if len(group) < 2 {
+// processUnderline processes SGR 4 and its style subparameter.
+func (pp *processorPerformer) processUnderline(group []uint16) {
+	style := uint16(1)
+	if len(group) > 1 {
+		style = group[1]
+	}
+
+	var attr Attr
+	switch style {
+	case 0:
+		pp.handler.UnsetAttribute(AttrAnyUnderline)
+		return
+	case 1:
+		attr = AttrUnderline
+	case 2:
+		attr = AttrDoubleUnderline
+	case 3:
+		attr = AttrCurlyUnderline
+	case 4:
+		attr = AttrDottedUnderline
+	case 5:
+		attr = AttrDashedUnderline
+	default:
 		return
 	}
 
-	var color Color
+	// The new style replaces any other underline style
+	pp.handler.UnsetAttribute(AttrAnyUnderline &^ attr)
+	pp.handler.SetAttribute(attr)
+}
 
-	switch group[1] {
-	case 2:
-		// RGB color
-		if len(group) >= 5 {
-			r := uint8(minUint16(group[2], 255))
-			g := uint8(minUint16(group[3], 255))
-			b := uint8(minUint16(group[4], 255))
-			color = NewRgbColor(r, g, b)
+// parseExtendedColor parses an extended color (SGR 38, 48 and 58) starting at groups[0].
+// Both the colon form (38:2:r:g:b, 38:2:cs:r:g:b, 38:2::r:g:b, 38:5:n) and the
+// semicolon form (38;2;r;g;b, 38;5;n) are accepted. It returns the color and the
+// number of parameter groups consumed.
+func parseExtendedColor(groups [][]uint16) (Color, int, bool) {
+	group := groups[0]
+
+	if len(group) > 1 {
+		// Colon form: everything is in a single group
+		switch group[1] {
+		case 2:
+			switch {
+			case len(group) >= 6:
+				// Colorspace identifier present (possibly empty)
+				return rgbColor(group[3], group[4], group[5]), 1, true
+			case len(group) == 5:
+				return rgbColor(group[2], group[3], group[4]), 1, true
+			}
+		case 5:
+			if len(group) >= 3 {
+				return NewIndexedColor(uint8(minUint16(group[2], 255))), 1, true
+			}
 		}
+		return Color{}, 1, false
+	}
 
+	// Semicolon form: the color is spread over the following groups
+	if len(groups) < 2 || len(groups[1]) == 0 {
+		return Color{}, 1, false
+	}
+	switch groups[1][0] {
+	case 2:
+		if len(groups) < 5 || len(groups[2]) == 0 || len(groups[3]) == 0 || len(groups[4]) == 0 {
+			return Color{}, len(groups), false
+		}
+		return rgbColor(groups[2][0], groups[3][0], groups[4][0]), 5, true
 	case 5:
-		// 256-color palette
-		if len(group) >= 3 {
-			idx := uint8(minUint16(group[2], 255))
-			color = NewIndexedColor(idx)
+		if len(groups) < 3 || len(groups[2]) == 0 {
+			return Color{}, len(groups), false
 		}
+		return NewIndexedColor(uint8(minUint16(groups[2][0], 255))), 3, true
 	}
+	return Color{}, 2, false
+}
 
-	if isForeground {
-		pp.handler.SetForeground(color)
-	} else {
-		pp.handler.SetBackground(color)
-	}
+// rgbColor creates an RGB color from parameter values, clamping each channel.
+func rgbColor(r, g, b uint16) Color {
+	return NewRgbColor(uint8(minUint16(r, 255)), uint8(minUint16(g, 255)), uint8(minUint16(b, 255)))
 }
 
 // getParam gets a parameter value with defaults.
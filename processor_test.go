@@ -1,6 +1,8 @@
 package govte
 
 import (
+	"bytes"
+	"fmt"
 	"testing"
 	"time"
 
@@ -364,3 +366,664 @@ func TestMinUint16(t *testing.T) {
 	assert.Equal(t, uint16(0), minUint16(0, 100))
 	assert.Equal(t, uint16(255), minUint16(1000, 255))
 }
+
+// CursorHandler is a test handler that tracks cursor appearance
+type CursorHandler struct {
+	NoopHandler
+	styles   []CursorStyle
+	visible  []bool
+	modesSet []Mode
+}
+
+// SetCursorStyle implements Handler
+func (h *CursorHandler) SetCursorStyle(style CursorStyle) {
+	h.styles = append(h.styles, style)
+}
+
+// SetCursorVisible implements Handler
+func (h *CursorHandler) SetCursorVisible(visible bool) {
+	h.visible = append(h.visible, visible)
+}
+
+// SetMode implements Handler
+func (h *CursorHandler) SetMode(mode Mode) {
+	h.modesSet = append(h.modesSet, mode)
+}
+
+func TestProcessorCursorStyle(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence string
+		expected []CursorStyle
+	}{
+		{"default", "\x1b[ q", []CursorStyle{{Shape: CursorShapeBlock, Blinking: true}}},
+		{"blinking block", "\x1b[1 q", []CursorStyle{{Shape: CursorShapeBlock, Blinking: true}}},
+		{"steady block", "\x1b[2 q", []CursorStyle{{Shape: CursorShapeBlock}}},
+		{"blinking underline", "\x1b[3 q", []CursorStyle{{Shape: CursorShapeUnderline, Blinking: true}}},
+		{"steady underline", "\x1b[4 q", []CursorStyle{{Shape: CursorShapeUnderline}}},
+		{"blinking bar", "\x1b[5 q", []CursorStyle{{Shape: CursorShapeBeam, Blinking: true}}},
+		{"steady bar", "\x1b[6 q", []CursorStyle{{Shape: CursorShapeBeam}}},
+		{"unknown style", "\x1b[9 q", nil},
+		{"without space intermediate", "\x1b[2q", nil},
+		{
+			"blink mode keeps shape",
+			"\x1b[6 q\x1b[?12h\x1b[?12l",
+			[]CursorStyle{{Shape: CursorShapeBeam}, {Shape: CursorShapeBeam, Blinking: true}, {Shape: CursorShapeBeam}},
+		},
+		{
+			"RIS resets the shape",
+			"\x1b[6 q\x1bc\x1b[?12h",
+			[]CursorStyle{{Shape: CursorShapeBeam}, {Shape: CursorShapeBlock, Blinking: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &CursorHandler{}
+			p := NewProcessor(h)
+
+			p.Advance(h, []byte(tt.sequence))
+			assert.Equal(t, tt.expected, h.styles)
+		})
+	}
+}
+
+func TestProcessorCursorVisibility(t *testing.T) {
+	h := &CursorHandler{}
+	p := NewProcessor(h)
+
+	p.Advance(h, []byte("\x1b[?25l\x1b[?25h"))
+
+	assert.Equal(t, []bool{false, true}, h.visible)
+	// The mode is still reported through SetMode
+	assert.Equal(t, []Mode{ModeShowCursor}, h.modesSet)
+}
+
+// SGRHandler is a test handler that tracks SGR operations
+type SGRHandler struct {
+	NoopHandler
+	set             []Attr
+	unset           []Attr
+	fonts           []int
+	foreground      []Color
+	background      []Color
+	underlineColors []Color
+}
+
+// SetAttribute implements Handler
+func (h *SGRHandler) SetAttribute(attr Attr) {
+	h.set = append(h.set, attr)
+}
+
+// UnsetAttribute implements Handler
+func (h *SGRHandler) UnsetAttribute(attr Attr) {
+	h.unset = append(h.unset, attr)
+}
+
+// SetFont implements Handler
+func (h *SGRHandler) SetFont(font int) {
+	h.fonts = append(h.fonts, font)
+}
+
+// SetForeground implements Handler
+func (h *SGRHandler) SetForeground(color Color) {
+	h.foreground = append(h.foreground, color)
+}
+
+// SetBackground implements Handler
+func (h *SGRHandler) SetBackground(color Color) {
+	h.background = append(h.background, color)
+}
+
+// SetUnderlineColor implements Handler
+func (h *SGRHandler) SetUnderlineColor(color Color) {
+	h.underlineColors = append(h.underlineColors, color)
+}
+
+func TestProcessorExtendedSGR(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence string
+		set      []Attr
+		unset    []Attr
+	}{
+		{"curly underline", "\x1b[4:3m", []Attr{AttrCurlyUnderline}, []Attr{AttrAnyUnderline &^ AttrCurlyUnderline}},
+		{"dotted underline", "\x1b[4:4m", []Attr{AttrDottedUnderline}, []Attr{AttrAnyUnderline &^ AttrDottedUnderline}},
+		{"dashed underline", "\x1b[4:5m", []Attr{AttrDashedUnderline}, []Attr{AttrAnyUnderline &^ AttrDashedUnderline}},
+		{"underline off via subparameter", "\x1b[4:0m", nil, []Attr{AttrAnyUnderline}},
+		{"plain underline replaces curly", "\x1b[4:3m\x1b[4m", []Attr{AttrCurlyUnderline, AttrUnderline}, []Attr{AttrAnyUnderline &^ AttrCurlyUnderline, AttrAnyUnderline &^ AttrUnderline}},
+		{"double underline", "\x1b[21m", []Attr{AttrDoubleUnderline}, []Attr{AttrAnyUnderline}},
+		{"underline off", "\x1b[24m", nil, []Attr{AttrAnyUnderline}},
+		{"bold and dim off", "\x1b[22m", nil, []Attr{AttrBold | AttrDim}},
+		{"fraktur", "\x1b[20m", []Attr{AttrFraktur}, nil},
+		{"italic and fraktur off", "\x1b[23m", nil, []Attr{AttrItalic | AttrFraktur}},
+		{"overline", "\x1b[53m", []Attr{AttrOverline}, nil},
+		{"overline off", "\x1b[55m", nil, []Attr{AttrOverline}},
+		{"superscript", "\x1b[73m", []Attr{AttrSuperscript}, []Attr{AttrSubscript}},
+		{"subscript", "\x1b[74m", []Attr{AttrSubscript}, []Attr{AttrSuperscript}},
+		{"super/subscript off", "\x1b[75m", nil, []Attr{AttrSuperscript | AttrSubscript}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &SGRHandler{}
+			p := NewProcessor(h)
+
+			p.Advance(h, []byte(tt.sequence))
+			assert.Equal(t, tt.set, h.set)
+			assert.Equal(t, tt.unset, h.unset)
+		})
+	}
+}
+
+func TestProcessorSGRFonts(t *testing.T) {
+	h := &SGRHandler{}
+	p := NewProcessor(h)
+
+	p.Advance(h, []byte("\x1b[11m\x1b[19m\x1b[10m"))
+	assert.Equal(t, []int{1, 9, 0}, h.fonts)
+}
+
+func TestProcessorSGRExtendedColorForms(t *testing.T) {
+	rgb := NewRgbColor(10, 20, 30)
+	tests := []struct {
+		name       string
+		sequence   string
+		foreground []Color
+		background []Color
+		underline  []Color
+		set        []Attr
+	}{
+		{"semicolon RGB", "\x1b[38;2;10;20;30m", []Color{rgb}, nil, nil, nil},
+		{"semicolon indexed", "\x1b[48;5;200m", nil, []Color{NewIndexedColor(200)}, nil, nil},
+		{"colon RGB", "\x1b[38:2:10:20:30m", []Color{rgb}, nil, nil, nil},
+		{"colon RGB with empty colorspace", "\x1b[48:2::10:20:30m", nil, []Color{rgb}, nil, nil},
+		{"colon RGB with colorspace", "\x1b[38:2:1:10:20:30m", []Color{rgb}, nil, nil, nil},
+		{"underline color semicolon", "\x1b[58;2;10;20;30m", nil, nil, []Color{rgb}, nil},
+		{"underline color colon", "\x1b[58:5:9m", nil, nil, []Color{NewIndexedColor(9)}, nil},
+		{"underline color reset", "\x1b[59m", nil, nil, []Color{NewNamedColor(Foreground)}, nil},
+		{
+			"semicolon color followed by attribute",
+			"\x1b[38;2;10;20;30;1m",
+			[]Color{rgb}, nil, nil, []Attr{AttrBold},
+		},
+		{"incomplete color consumes the rest", "\x1b[38;2;1m", nil, nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &SGRHandler{}
+			p := NewProcessor(h)
+
+			p.Advance(h, []byte(tt.sequence))
+			assert.Equal(t, tt.foreground, h.foreground)
+			assert.Equal(t, tt.background, h.background)
+			assert.Equal(t, tt.underline, h.underlineColors)
+			assert.Equal(t, tt.set, h.set)
+		})
+	}
+}
+
+// MarginHandler is a test handler that tracks margin and cursor save operations
+type MarginHandler struct {
+	NoopHandler
+	margins [][2]int
+	saves   int
+}
+
+// SetLeftRightMargins implements Handler
+func (h *MarginHandler) SetLeftRightMargins(left, right int) {
+	h.margins = append(h.margins, [2]int{left, right})
+}
+
+// SaveCursorPosition implements Handler
+func (h *MarginHandler) SaveCursorPosition() {
+	h.saves++
+}
+
+func TestProcessorLeftRightMargins(t *testing.T) {
+	h := &MarginHandler{}
+	p := NewProcessor(h)
+
+	// CSI s saves the cursor until DECLRMM is enabled
+	p.Advance(h, []byte("\x1b[5;10s"))
+	assert.Equal(t, 1, h.saves)
+	assert.Empty(t, h.margins)
+
+	p.Advance(h, []byte("\x1b[?69h\x1b[5;10s\x1b[s"))
+	assert.Equal(t, 1, h.saves)
+	assert.Equal(t, [][2]int{{5, 10}, {1, 0}}, h.margins)
+	assert.True(t, p.IsMode(ModeLeftRightMargin))
+
+	p.Advance(h, []byte("\x1b[?69l\x1b[s"))
+	assert.Equal(t, 2, h.saves)
+}
+
+// ProtectionHandler is a test handler that tracks selective erase operations
+type ProtectionHandler struct {
+	NoopHandler
+	protection  []bool
+	lineClears  []LineClearMode
+	screenClear []ClearMode
+	plainClears int
+}
+
+// SetCharacterProtection implements Handler
+func (h *ProtectionHandler) SetCharacterProtection(protected bool) {
+	h.protection = append(h.protection, protected)
+}
+
+// SelectiveClearLine implements Handler
+func (h *ProtectionHandler) SelectiveClearLine(mode LineClearMode) {
+	h.lineClears = append(h.lineClears, mode)
+}
+
+// SelectiveClearScreen implements Handler
+func (h *ProtectionHandler) SelectiveClearScreen(mode ClearMode) {
+	h.screenClear = append(h.screenClear, mode)
+}
+
+// ClearScreen implements Handler
+func (h *ProtectionHandler) ClearScreen(mode ClearMode) {
+	h.plainClears++
+}
+
+func TestProcessorSelectiveErase(t *testing.T) {
+	h := &ProtectionHandler{}
+	p := NewProcessor(h)
+
+	p.Advance(h, []byte("\x1b[1\"q\x1b[0\"q\x1b[2\"q\x1bV\x1bW"))
+	assert.Equal(t, []bool{true, false, false, true, false}, h.protection)
+
+	p.Advance(h, []byte("\x1b[?K\x1b[?2K\x1b[?1J\x1b[2J"))
+	assert.Equal(t, []LineClearMode{LineClearRight, LineClearAll}, h.lineClears)
+	assert.Equal(t, []ClearMode{ClearAbove}, h.screenClear)
+	assert.Equal(t, 1, h.plainClears)
+}
+
+// RectangleHandler is a test handler that tracks rectangular area operations
+type RectangleHandler struct {
+	NoopHandler
+	calls []string
+}
+
+// FillRectangle implements Handler
+func (h *RectangleHandler) FillRectangle(c rune, area Rectangle) {
+	h.calls = append(h.calls, fmt.Sprintf("fill %c %v", c, area))
+}
+
+// EraseRectangle implements Handler
+func (h *RectangleHandler) EraseRectangle(area Rectangle) {
+	h.calls = append(h.calls, fmt.Sprintf("erase %v", area))
+}
+
+// SelectiveEraseRectangle implements Handler
+func (h *RectangleHandler) SelectiveEraseRectangle(area Rectangle) {
+	h.calls = append(h.calls, fmt.Sprintf("selective erase %v", area))
+}
+
+// CopyRectangle implements Handler
+func (h *RectangleHandler) CopyRectangle(area Rectangle, top, left int) {
+	h.calls = append(h.calls, fmt.Sprintf("copy %v to %d,%d", area, top, left))
+}
+
+// ChangeRectangleAttributes implements Handler
+func (h *RectangleHandler) ChangeRectangleAttributes(area Rectangle, attrs []int) {
+	h.calls = append(h.calls, fmt.Sprintf("change %v %v", area, attrs))
+}
+
+// ReverseRectangleAttributes implements Handler
+func (h *RectangleHandler) ReverseRectangleAttributes(area Rectangle, attrs []int) {
+	h.calls = append(h.calls, fmt.Sprintf("reverse %v %v", area, attrs))
+}
+
+// SetAttributeChangeExtent implements Handler
+func (h *RectangleHandler) SetAttributeChangeExtent(extent AttributeChangeExtent) {
+	h.calls = append(h.calls, fmt.Sprintf("extent %d", extent))
+}
+
+func TestProcessorRectangularAreas(t *testing.T) {
+	h := &RectangleHandler{}
+	p := NewProcessor(h)
+
+	p.Advance(h, []byte("\x1b[35;2;3;4;5$x\x1b[7$x\x1b[$z\x1b[2;2${"))
+	p.Advance(h, []byte("\x1b[1;1;5;10;1;3;4;1$v\x1b[;;;;1;7$r\x1b[2;2;3;3$t\x1b[2*x\x1b[*x"))
+	assert.Equal(t, []string{
+		"fill # {2 3 4 5}",
+		"erase {1 1 0 0}",
+		"selective erase {2 2 0 0}",
+		"copy {1 1 5 10} to 3,4",
+		"change {1 1 0 0} [1 7]",
+		"reverse {2 2 3 3} [0]",
+		"extent 1",
+		"extent 0",
+	}, h.calls)
+}
+
+// PageSizeHandler is a test handler that tracks page size changes
+type PageSizeHandler struct {
+	NoopHandler
+	columns []int
+	lines   []int
+}
+
+// SetColumnsPerPage implements Handler
+func (h *PageSizeHandler) SetColumnsPerPage(columns int) {
+	h.columns = append(h.columns, columns)
+}
+
+// SetLinesPerPage implements Handler
+func (h *PageSizeHandler) SetLinesPerPage(lines int) {
+	h.lines = append(h.lines, lines)
+}
+
+func TestProcessorPageSize(t *testing.T) {
+	h := &PageSizeHandler{}
+	p := NewProcessor(h)
+
+	// DECSLPP is a window operation that must be allowed
+	p.Advance(h, []byte("\x1b[48t"))
+	assert.Empty(t, h.lines)
+
+	p.AllowWindowOp(WindowOpResizeLines, true)
+	p.Advance(h, []byte("\x1b[132$|\x1b[$|\x1b[100$|\x1b[48t\x1b[18t"))
+	assert.Equal(t, []int{132, 80, 132}, h.columns)
+	assert.Equal(t, []int{48}, h.lines)
+
+	p.Advance(h, []byte("\x1b[?40h\x1b[?3h"))
+	assert.True(t, p.IsMode(ModeAllowColumnChange))
+	assert.True(t, p.IsMode(ModeColumn))
+}
+
+// TitleHandler is a test handler that tracks title changes and title stack operations
+type TitleHandler struct {
+	NoopHandler
+	titles  []string
+	icons   []string
+	pushed  []TitleTarget
+	popped  []TitleTarget
+	reports []TitleTarget
+}
+
+// SetTitle implements Handler
+func (h *TitleHandler) SetTitle(title string) {
+	h.titles = append(h.titles, title)
+}
+
+// SetIconName implements Handler
+func (h *TitleHandler) SetIconName(name string) {
+	h.icons = append(h.icons, name)
+}
+
+// PushTitle implements Handler
+func (h *TitleHandler) PushTitle(target TitleTarget) {
+	h.pushed = append(h.pushed, target)
+}
+
+// PopTitle implements Handler
+func (h *TitleHandler) PopTitle(target TitleTarget) {
+	h.popped = append(h.popped, target)
+}
+
+// ReportTitle implements Handler
+func (h *TitleHandler) ReportTitle(target TitleTarget) {
+	h.reports = append(h.reports, target)
+}
+
+func TestProcessorTitles(t *testing.T) {
+	h := &TitleHandler{}
+	p := NewProcessor(h)
+
+	p.Advance(h, []byte("\x1b]0;both\x07\x1b]1;icon\x07\x1b]2;title\x07"))
+	assert.Equal(t, []string{"both", "title"}, h.titles)
+	assert.Equal(t, []string{"both", "icon"}, h.icons)
+
+	p.Advance(h, []byte("\x1b[22t\x1b[22;1t\x1b[22;2t\x1b[23;2t\x1b[23t"))
+	assert.Equal(t, []TitleTarget{TitleBoth, TitleIconName, TitleWindow}, h.pushed)
+	assert.Equal(t, []TitleTarget{TitleWindow, TitleBoth}, h.popped)
+
+	// Title reports must be allowed explicitly
+	p.Advance(h, []byte("\x1b[20t\x1b[21t"))
+	assert.Empty(t, h.reports)
+
+	p.AllowWindowOp(WindowOpReportIconName, true)
+	p.AllowWindowOp(WindowOpReportTitle, true)
+	p.Advance(h, []byte("\x1b[20t\x1b[21t"))
+	assert.Equal(t, []TitleTarget{TitleIconName, TitleWindow}, h.reports)
+}
+
+// WindowHandler is a test handler that tracks window operations
+type WindowHandler struct {
+	NoopHandler
+	iconified []bool
+	resizes   [][2]int
+	regions   [][2]int
+}
+
+// SetIconified implements Handler
+func (h *WindowHandler) SetIconified(iconified bool) {
+	h.iconified = append(h.iconified, iconified)
+}
+
+// ResizeTextArea implements Handler
+func (h *WindowHandler) ResizeTextArea(columns, lines int) {
+	h.resizes = append(h.resizes, [2]int{columns, lines})
+}
+
+// SetScrollingRegion implements Handler
+func (h *WindowHandler) SetScrollingRegion(top, bottom int) {
+	h.regions = append(h.regions, [2]int{top, bottom})
+}
+
+func TestProcessorWindowReports(t *testing.T) {
+	h := &WindowHandler{}
+	var out bytes.Buffer
+	p := NewProcessorWithBuffer(&out, h)
+
+	p.Advance(h, []byte("\x1b[18t"))
+	assert.Equal(t, "\x1b[8;24;80t", out.String())
+
+	// Pixel reports need the cell size
+	out.Reset()
+	p.Resize(100, 30)
+	p.Advance(h, []byte("\x1b[14t\x1b[16t"))
+	assert.Empty(t, out.String())
+
+	p.SetCellSize(9, 18)
+	p.Advance(h, []byte("\x1b[18t\x1b[14t\x1b[16t"))
+	assert.Equal(t, "\x1b[8;30;100t\x1b[4;540;900t\x1b[6;18;9t", out.String())
+
+	// Forbidden reports are not answered
+	out.Reset()
+	p.AllowWindowOp(WindowOpReportTextAreaSize, false)
+	p.Advance(h, []byte("\x1b[18t"))
+	assert.Empty(t, out.String())
+
+	// Reports are written to the output, not to the synchronized update buffer
+	p.AllowWindowOp(WindowOpReportTextAreaSize, true)
+	p.SetSyncTimeout(0)
+	p.BeginSynchronizedUpdate()
+	p.Advance(h, []byte("\x1b[18t"))
+	assert.Equal(t, "\x1b[8;30;100t", out.String())
+	assert.Empty(t, p.syncState.buffer)
+
+	// DECSTBM defaults to the bottom of the text area
+	p.Advance(h, []byte("\x1b[5r\x1b[r"))
+	assert.Equal(t, [][2]int{{5, 30}, {1, 30}}, h.regions)
+}
+
+func TestProcessorWindowRequests(t *testing.T) {
+	h := &WindowHandler{}
+	p := NewProcessor(h)
+
+	// Requests that change the window are forbidden by default
+	p.Advance(h, []byte("\x1b[2t\x1b[8;40;120t"))
+	assert.Empty(t, h.iconified)
+	assert.Empty(t, h.resizes)
+
+	p.AllowWindowOp(WindowOpIconify, true)
+	p.AllowWindowOp(WindowOpDeiconify, true)
+	p.AllowWindowOp(WindowOpResize, true)
+	p.Advance(h, []byte("\x1b[2t\x1b[1t\x1b[8;40;120t\x1b[8;;100t\x1b[8;50t"))
+	assert.Equal(t, []bool{true, false}, h.iconified)
+	assert.Equal(t, [][2]int{{120, 40}, {100, 24}, {80, 50}}, h.resizes)
+}
+
+// ModeHandler is a test handler that tracks mode changes
+type ModeHandler struct {
+	NoopHandler
+	set   []Mode
+	reset []Mode
+}
+
+// SetMode implements Handler
+func (h *ModeHandler) SetMode(mode Mode) {
+	h.set = append(h.set, mode)
+}
+
+// ResetMode implements Handler
+func (h *ModeHandler) ResetMode(mode Mode) {
+	h.reset = append(h.reset, mode)
+}
+
+func TestProcessorModeTracking(t *testing.T) {
+	h := &ModeHandler{}
+	p := NewProcessor(h)
+
+	assert.True(t, p.IsMode(ModeAutoWrap))
+	assert.True(t, p.IsMode(ModeShowCursor))
+
+	p.Advance(h, []byte("\x1b[4;20h\x1b[?2026;2004h"))
+	assert.True(t, p.IsMode(ModeInsert))
+	assert.True(t, p.IsMode(ModeAutomaticNewline))
+	assert.True(t, p.IsMode(ModeSynchronizedOutput))
+	assert.True(t, p.IsMode(ModeBracketedPaste))
+
+	p.Advance(h, []byte("\x1b[4l\x1b[?7l"))
+	assert.False(t, p.IsMode(ModeInsert))
+	assert.False(t, p.IsMode(ModeAutoWrap))
+	assert.Equal(t, []Mode{ModeInsert, ModeAutoWrap}, h.reset)
+}
+
+func TestProcessorRequestMode(t *testing.T) {
+	h := &ModeHandler{}
+	var out bytes.Buffer
+	p := NewProcessorWithBuffer(&out, h)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"\x1b[?2026$p", "\x1b[?2026;2$y"},
+		{"\x1b[?2026h\x1b[?2026$p", "\x1b[?2026;1$y"},
+		{"\x1b[?2027$p", "\x1b[?2027;2$y"},
+		{"\x1b[?7$p", "\x1b[?7;1$y"},
+		{"\x1b[4h\x1b[4$p", "\x1b[4;1$y"},
+		{"\x1b[20$p", "\x1b[20;2$y"},
+		{"\x1b[?9999$p", "\x1b[?9999;0$y"},
+		{"\x1b[3$p", "\x1b[3;0$y"},
+	}
+
+	for _, tt := range tests {
+		out.Reset()
+		p.Advance(h, []byte(tt.input))
+		assert.Equal(t, tt.expected, out.String(), "input %q", tt.input)
+	}
+
+	// Permanent modes ignore SM and RM
+	p.SetPermanentMode(ModeGraphemeClustering, true)
+	p.SetPermanentMode(ModeKeyboardAction, false)
+	out.Reset()
+	p.Advance(h, []byte("\x1b[?2027l\x1b[?2027$p\x1b[2h\x1b[2$p"))
+	assert.Equal(t, "\x1b[?2027;3$y\x1b[2;4$y", out.String())
+	assert.True(t, p.IsMode(ModeGraphemeClustering))
+
+	// The handler is not told about changes to permanent modes
+	assert.NotContains(t, h.reset, ModeGraphemeClustering)
+	assert.NotContains(t, h.set, ModeKeyboardAction)
+}
+
+func TestProcessorSaveRestoreModes(t *testing.T) {
+	h := &ModeHandler{}
+	p := NewProcessor(h)
+
+	p.Advance(h, []byte("\x1b[?2004h\x1b[?2004;7;1s\x1b[?2004l\x1b[?7l\x1b[?1h"))
+	assert.False(t, p.IsMode(ModeBracketedPaste))
+	assert.True(t, p.IsMode(ModeApplicationCursor))
+
+	h.set, h.reset = nil, nil
+	p.Advance(h, []byte("\x1b[?2004;7;1;25r"))
+	assert.True(t, p.IsMode(ModeBracketedPaste))
+	assert.True(t, p.IsMode(ModeAutoWrap))
+	assert.False(t, p.IsMode(ModeApplicationCursor))
+	assert.Equal(t, []Mode{ModeBracketedPaste, ModeAutoWrap}, h.set)
+	assert.Equal(t, []Mode{ModeApplicationCursor}, h.reset)
+}
+
+// LineSizeHandler is a test handler that tracks line size changes and DECALN
+type LineSizeHandler struct {
+	NoopHandler
+	sizes      []LineSize
+	alignments int
+}
+
+// SetLineSize implements Handler
+func (h *LineSizeHandler) SetLineSize(size LineSize) {
+	h.sizes = append(h.sizes, size)
+}
+
+// ScreenAlignmentTest implements Handler
+func (h *LineSizeHandler) ScreenAlignmentTest() {
+	h.alignments++
+}
+
+func TestProcessorLineSize(t *testing.T) {
+	h := &LineSizeHandler{}
+	p := NewProcessor(h)
+
+	p.Advance(h, []byte("\x1b#3\x1b#4\x1b#6\x1b#5\x1b#8\x1b#1"))
+	assert.Equal(t, []LineSize{
+		LineSizeDoubleHeightTop,
+		LineSizeDoubleHeightBottom,
+		LineSizeDoubleWidth,
+		LineSizeSingle,
+	}, h.sizes)
+	assert.Equal(t, 1, h.alignments)
+}
+
+func TestProcessorModeReset(t *testing.T) {
+	h := &ModeHandler{}
+	var out bytes.Buffer
+	p := NewProcessorWithBuffer(&out, h)
+	p.SetPermanentMode(ModeGraphemeClustering, true)
+
+	// RIS restores the initial modes and forgets the saved ones
+	p.Advance(h, []byte("\x1b[?7l\x1b[?2004h\x1b[?2004s\x1bc"))
+	assert.True(t, p.IsMode(ModeAutoWrap))
+	assert.False(t, p.IsMode(ModeBracketedPaste))
+	assert.True(t, p.IsMode(ModeGraphemeClustering))
+
+	p.Advance(h, []byte("\x1b[?2004r\x1b[?2004$p\x1b[?7$p"))
+	assert.Equal(t, "\x1b[?2004;2$y\x1b[?7;1$y", out.String())
+
+	// Processor.Reset does the same
+	p.Advance(h, []byte("\x1b[?25l"))
+	p.Reset()
+	assert.True(t, p.IsMode(ModeShowCursor))
+}
+
+func TestProcessorRecognizedModes(t *testing.T) {
+	h := &ModeHandler{}
+	var out bytes.Buffer
+	p := NewProcessorWithBuffer(&out, h)
+
+	p.Advance(h, []byte("\x1b[?1000h\x1b[?1000$p\x1b[?1006$p\x1b[?1004$p"))
+	assert.Equal(t, "\x1b[?1000;1$y\x1b[?1006;2$y\x1b[?1004;2$y", out.String())
+
+	// Embedders choose which modes they report
+	out.Reset()
+	p.SetModeRecognized(ModeFocusEvents, false)
+	p.SetModeRecognized(ModeFromParam(1016, true), true)
+	p.Advance(h, []byte("\x1b[?1004$p\x1b[?1016$p"))
+	assert.Equal(t, "\x1b[?1004;0$y\x1b[?1016;2$y", out.String())
+}
@@ -0,0 +1,63 @@
+package govte
+
+import "strconv"
+
+// ProgressState represents the state of a progress indicator reported
+// through the ConEmu / Windows Terminal OSC 9 ; 4 protocol.
+type ProgressState uint8
+
+const (
+	ProgressHidden        ProgressState = iota // Remove the progress indicator
+	ProgressNormal                             // Show progress as a percentage
+	ProgressError                              // Show progress in the error state
+	ProgressIndeterminate                      // Show activity without a percentage
+	ProgressPaused                             // Show progress in the paused state
+)
+
+// String returns the string representation of ProgressState.
+func (s ProgressState) String() string {
+	switch s {
+	case ProgressHidden:
+		return "Hidden"
+	case ProgressNormal:
+		return "Normal"
+	case ProgressError:
+		return "Error"
+	case ProgressIndeterminate:
+		return "Indeterminate"
+	case ProgressPaused:
+		return "Paused"
+	default:
+		return "Unknown"
+	}
+}
+
+// ParseProgress parses the parameters of an OSC 9 ; 4 ; state ; percent sequence.
+// A missing state means hidden and a missing percent means 0; the percentage
+// is clamped to 0-100. It returns false if the sequence is not a valid
+// progress report.
+func ParseProgress(params [][]byte) (ProgressState, int, bool) {
+	if len(params) < 2 || string(params[0]) != "9" || string(params[1]) != "4" {
+		return ProgressHidden, 0, false
+	}
+
+	state := 0
+	if len(params) > 2 && len(params[2]) > 0 {
+		value, err := strconv.Atoi(string(params[2]))
+		if err != nil || value < 0 || value > int(ProgressPaused) {
+			return ProgressHidden, 0, false
+		}
+		state = value
+	}
+
+	percent := 0
+	if len(params) > 3 && len(params[3]) > 0 {
+		value, err := strconv.Atoi(string(params[3]))
+		if err != nil || value < 0 {
+			return ProgressHidden, 0, false
+		}
+		percent = min(value, 100)
+	}
+
+	return ProgressState(state), percent, true //nolint:gosec // state is validated above
+}
@@ -0,0 +1,81 @@
+package govte
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ProgressHandler is a test handler that records progress reports
+type ProgressHandler struct {
+	NoopHandler
+	states   []ProgressState
+	percents []int
+	notified int
+}
+
+// SetProgress implements Handler
+func (h *ProgressHandler) SetProgress(state ProgressState, percent int) {
+	h.states = append(h.states, state)
+	h.percents = append(h.percents, percent)
+}
+
+// Notify implements Handler
+func (h *ProgressHandler) Notify(notification Notification) {
+	h.notified++
+}
+
+func TestProgressStateString(t *testing.T) {
+	assert.Equal(t, "Hidden", ProgressHidden.String())
+	assert.Equal(t, "Normal", ProgressNormal.String())
+	assert.Equal(t, "Error", ProgressError.String())
+	assert.Equal(t, "Indeterminate", ProgressIndeterminate.String())
+	assert.Equal(t, "Paused", ProgressPaused.String())
+	assert.Equal(t, "Unknown", ProgressState(42).String())
+}
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  []string
+		state   ProgressState
+		percent int
+		ok      bool
+	}{
+		{"normal", []string{"9", "4", "1", "50"}, ProgressNormal, 50, true},
+		{"hidden", []string{"9", "4", "0"}, ProgressHidden, 0, true},
+		{"defaults", []string{"9", "4"}, ProgressHidden, 0, true},
+		{"error", []string{"9", "4", "2", "75"}, ProgressError, 75, true},
+		{"indeterminate", []string{"9", "4", "3"}, ProgressIndeterminate, 0, true},
+		{"paused", []string{"9", "4", "4", "10"}, ProgressPaused, 10, true},
+		{"clamped", []string{"9", "4", "1", "250"}, ProgressNormal, 100, true},
+		{"invalid state", []string{"9", "4", "7", "10"}, ProgressHidden, 0, false},
+		{"invalid percent", []string{"9", "4", "1", "x"}, ProgressHidden, 0, false},
+		{"other sub-command", []string{"9", "9", "/tmp"}, ProgressHidden, 0, false},
+		{"other OSC", []string{"2", "4"}, ProgressHidden, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := make([][]byte, len(tt.params))
+			for i, p := range tt.params {
+				params[i] = []byte(p)
+			}
+			state, percent, ok := ParseProgress(params)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.state, state)
+			assert.Equal(t, tt.percent, percent)
+		})
+	}
+}
+
+func TestProcessorProgress(t *testing.T) {
+	h := &ProgressHandler{}
+	p := NewProcessor(h)
+
+	p.Advance(h, []byte("\x1b]9;4;1;25\x07\x1b]9;4;3\x1b\\\x1b]9;4;0\x07\x1b]9;hello\x07"))
+
+	assert.Equal(t, []ProgressState{ProgressNormal, ProgressIndeterminate, ProgressHidden}, h.states)
+	assert.Equal(t, []int{25, 0, 0}, h.percents)
+	assert.Equal(t, 1, h.notified)
+}
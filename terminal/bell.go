@@ -0,0 +1,84 @@
+//! Terminal bell
+//! Audible bells (BEL) and visual bells (a short DECSCNM flash)
+
+package terminal
+
+import "time"
+
+// visualBellWindow is the longest reverse video flash reported as a visual bell
+const visualBellWindow = 500 * time.Millisecond
+
+// BellKind tells how the application rang the bell
+type BellKind int
+
+const (
+	// BellAudible is the BEL control character
+	BellAudible BellKind = iota
+	// BellVisual is a short reverse video flash of the screen (DECSCNM)
+	BellVisual
+)
+
+// String returns the string representation of BellKind
+func (k BellKind) String() string {
+	switch k {
+	case BellAudible:
+		return "Audible"
+	case BellVisual:
+		return "Visual"
+	default:
+		return "Unknown"
+	}
+}
+
+// BellEvent is passed to the bell handler when the application rings the bell
+type BellEvent struct {
+	Kind BellKind
+	Time time.Time
+}
+
+// SetBellHandler sets a function that is called when the application rings
+// the bell; nil removes the handler
+func (tb *TerminalBuffer) SetBellHandler(handler func(BellEvent)) {
+	tb.bellHandler = handler
+}
+
+// IsReverseVideo reports whether the screen is in reverse video (DECSCNM).
+// The cells keep their styles; renders swap the colors of the whole screen.
+func (tb *TerminalBuffer) IsReverseVideo() bool {
+	return tb.reverseVideo
+}
+
+// ringBell reports a bell to the bell handler
+func (tb *TerminalBuffer) ringBell(kind BellKind, at time.Time) {
+	if tb.bellHandler != nil {
+		tb.bellHandler(BellEvent{Kind: kind, Time: at})
+	}
+}
+
+// setReverseVideo handles DECSCNM. Turning reverse video off shortly after
+// turning it on is a visual bell.
+func (tb *TerminalBuffer) setReverseVideo(enabled bool) {
+	if enabled == tb.reverseVideo {
+		return
+	}
+
+	now := tb.now()
+	if enabled {
+		tb.reverseVideoSince = now
+	} else if now.Sub(tb.reverseVideoSince) <= visualBellWindow {
+		tb.ringBell(BellVisual, now)
+	}
+	tb.reverseVideo = enabled
+}
+
+// reverseStyles swaps the foreground and background of styles by toggling
+// the reverse attribute
+func reverseStyles(styles CharacterStyles) CharacterStyles {
+	if styles.Reverse != nil && styles.Reverse.Type == AnsiCodeTypeOn {
+		styles.Reverse = nil
+	} else {
+		on := AnsiCodeOn()
+		styles.Reverse = &on
+	}
+	return styles
+}
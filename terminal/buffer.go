@@ -4,11 +4,16 @@
 package terminal
 
 import (
+	"io"
 	"strings"
+	"time"
 
 	"github.com/cliofy/govte"
 )
 
+// maxPageLines is the largest number of lines DECSLPP can set
+const maxPageLines = 255
+
 // TerminalBuffer implements a complete terminal buffer with VTE integration
 type TerminalBuffer struct {
 	// Screen dimensions
@@ -17,13 +22,97 @@ type TerminalBuffer struct {
 
 	// Terminal state
 	viewport     []Row
+	inactive     []Row // the screen that is not displayed
+	altScreen    bool
 	cursor       Cursor
 	savedCursor  *SavedCursor
-	title        *string
 	scrollRegion *ScrollRegion
 
+	// Cursor saved by DECSC on the inactive screen
+	inactiveSavedCursor *SavedCursor
+
+	// Rows scrolled off the top of the screen
+	scrollback *Scrollback
+
 	// Current character styles
 	currentStyles CharacterStyles
+
+	// Character set designations and shift state
+	charsets CharsetState
+
+	// Character set used for the next printed character only (SS2, SS3)
+	singleShift       govte.CharsetIndex
+	singleShiftActive bool
+
+	// Columns with a tab stop
+	tabStops []bool
+
+	// Left and right margins (0-based, inclusive), used while DECLRMM is set
+	leftMargin   int
+	rightMargin  int
+	lrMarginMode bool
+
+	// DECOM - cursor addressing relative to the margins
+	originMode bool
+
+	// DECAWM - wrap to the next line after printing in the last column
+	autowrap bool
+
+	// IRM - printed characters shift the rest of the line right
+	insertMode bool
+
+	// LNM - line feed also returns the cursor to the left margin
+	newlineMode bool
+
+	// Display width of East Asian Ambiguous characters
+	ambiguousWidth AmbiguousWidth
+
+	// BCE - erased cells take the current background color
+	backgroundColorErase bool
+
+	// DECSACE - area changed by DECCARA and DECRARA
+	attributeExtent govte.AttributeChangeExtent
+
+	// Mode 40 - DECCOLM may switch between 80 and 132 columns
+	allowColumnChange bool
+
+	// Called when the application changes the page size
+	resizeHandler func(width, height int)
+
+	// DECSLPP may change the number of lines
+	pageResizeAllowed bool
+
+	// DEC private modes saved by XTSAVE
+	savedModes map[uint16]bool
+
+	// DECSCNM - the whole screen is shown in reverse video
+	reverseVideo      bool
+	reverseVideoSince time.Time
+
+	// Called when the application rings the bell
+	bellHandler func(BellEvent)
+	now         func() time.Time
+
+	// Last printed graphic character, repeated by REP
+	lastPrinted *rune
+
+	// Window title and icon name, with the stacks of CSI 22 t and CSI 23 t
+	title          string
+	iconName       string
+	titleStack     []string
+	iconStack      []string
+	titleReporting bool
+
+	// Replies to the application
+	responses io.Writer
+
+	// Desktop notifications received from the application
+	notifications       []govte.Notification
+	notificationDecoder govte.NotificationDecoder
+
+	// Latest progress report (OSC 9 ; 4)
+	progressState   govte.ProgressState
+	progressPercent int
 }
 
 // ScrollRegion represents the terminal scroll region
@@ -34,27 +123,109 @@ type ScrollRegion struct {
 
 // NewTerminalBuffer creates a new terminal buffer with specified dimensions
 func NewTerminalBuffer(width, height int) *TerminalBuffer {
-	viewport := make([]Row, height)
-	for i := range viewport {
-		viewport[i] = NewRowWithWidth(width)
-	}
+	viewport := blankRows(width, height)
 
 	return &TerminalBuffer{
 		width:         width,
 		height:        height,
 		viewport:      viewport,
+		inactive:      blankRows(width, height),
 		cursor:        NewCursor(),
+		scrollback:    NewScrollback(DefaultScrollbackLines, 0),
+		tabStops:      defaultTabStops(width),
+		rightMargin:   width - 1,
+		autowrap:      true,
 		currentStyles: DefaultCharacterStyles(),
+
+		backgroundColorErase: true,
+		now:                  time.Now,
 	}
 }
 
 // GetDisplay returns the rendered display as plain text
 func (tb *TerminalBuffer) GetDisplay() string {
+	return renderRows(tb.viewport)
+}
+
+// GetDisplayWithColors returns the rendered display with ANSI color codes
+func (tb *TerminalBuffer) GetDisplayWithColors() string {
+	return renderRowsWithColors(tb.viewport, tb.reverseVideo)
+}
+
+// GetFullDisplay returns the scrollback history followed by the display as plain text
+func (tb *TerminalBuffer) GetFullDisplay() string {
+	return renderRows(tb.historyAndViewport())
+}
+
+// GetFullDisplayWithColors returns the scrollback history followed by the display
+// with ANSI color codes
+func (tb *TerminalBuffer) GetFullDisplayWithColors() string {
+	return renderRowsWithColors(tb.historyAndViewport(), tb.reverseVideo)
+}
+
+// LogicalLines returns the text of the scrollback history and the display with
+// soft-wrapped rows joined back into the lines the application wrote
+func (tb *TerminalBuffer) LogicalLines() []string {
+	var lines []string
+	var current strings.Builder
+
+	for i, row := range tb.historyAndViewport() {
+		if i > 0 && row.IsCanonical {
+			lines = append(lines, strings.TrimRight(current.String(), " "))
+			current.Reset()
+		}
+		current.WriteString(row.ToString())
+	}
+	lines = append(lines, strings.TrimRight(current.String(), " "))
+
+	// Drop the blank rows below the last output, as GetDisplay does
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// HistoryLen returns the number of rows in the scrollback history
+func (tb *TerminalBuffer) HistoryLen() int {
+	return tb.scrollback.Len()
+}
+
+// HistoryRow returns a copy of a scrollback row, where 0 is the oldest row
+func (tb *TerminalBuffer) HistoryRow(index int) (Row, bool) {
+	row := tb.scrollback.Get(index)
+	if row == nil {
+		return Row{}, false
+	}
+	return row.Clone(), true
+}
+
+// SetScrollbackLimit sets the maximum number of rows and bytes kept in the
+// scrollback history; a limit of zero disables that bound
+func (tb *TerminalBuffer) SetScrollbackLimit(maxLines, maxBytes int) {
+	tb.scrollback.SetLimits(maxLines, maxBytes)
+}
+
+// ClearScrollback discards the scrollback history
+func (tb *TerminalBuffer) ClearScrollback() {
+	tb.scrollback.Clear()
+}
+
+// historyAndViewport returns the scrollback rows followed by the viewport rows
+func (tb *TerminalBuffer) historyAndViewport() []Row {
+	rows := make([]Row, 0, tb.scrollback.Len()+len(tb.viewport))
+	for i := 0; i < tb.scrollback.Len(); i++ {
+		rows = append(rows, *tb.scrollback.Get(i))
+	}
+	return append(rows, tb.viewport...)
+}
+
+// renderRows renders rows as plain text
+func renderRows(rows []Row) string {
 	var result strings.Builder
 
-	for i, row := range tb.viewport {
-		result.WriteString(row.ToString())
-		if i < len(tb.viewport)-1 {
+	for i, row := range rows {
+		result.WriteString(row.displayString())
+		if i < len(rows)-1 {
 			result.WriteString("\n")
 		}
 	}
@@ -62,15 +233,32 @@ func (tb *TerminalBuffer) GetDisplay() string {
 	return strings.TrimRight(result.String(), " \t\n")
 }
 
-// GetDisplayWithColors returns the rendered display with ANSI color codes
-func (tb *TerminalBuffer) GetDisplayWithColors() string {
+// renderRowsWithColors renders rows with ANSI color codes; in reverse video
+// every character is rendered with its colors swapped
+func renderRowsWithColors(rows []Row, reverseVideo bool) string {
 	var result strings.Builder
 	currentStyles := DefaultCharacterStyles()
 
-	for rowIdx, row := range tb.viewport {
-		for _, character := range row.Columns {
+	for rowIdx, row := range rows {
+		columns := row.Columns
+		if row.LineSize.IsDoubleWidth() {
+			// The line size sequence makes the terminal double the characters
+			result.WriteString(row.LineSize.escapeSequence())
+			columns = columns[:len(columns)/2]
+		}
+
+		for _, character := range columns {
+			if character.IsWideCharSpacer() {
+				continue
+			}
+
+			styles := character.Styles
+			if reverseVideo {
+				styles = reverseStyles(styles)
+			}
+
 			// Only emit style changes when styles actually change
-			if character.Styles.DiffersFrom(&currentStyles) {
+			if styles.DiffersFrom(&currentStyles) {
 				// Reset if we had any previous styles
 				defaultStyles := DefaultCharacterStyles()
 				if !currentStyles.equals(&defaultStyles) {
@@ -78,18 +266,19 @@ func (tb *TerminalBuffer) GetDisplayWithColors() string {
 				}
 
 				// Apply new styles
-				styleSequence := character.Styles.ToAnsiSequence()
+				styleSequence := styles.ToAnsiSequence()
 				if styleSequence != "" {
 					result.WriteString(styleSequence)
 				}
 
-				currentStyles = character.Styles
+				currentStyles = styles
 			}
 
 			result.WriteRune(character.Character)
+			result.WriteString(character.Combining)
 		}
 
-		if rowIdx < len(tb.viewport)-1 {
+		if rowIdx < len(rows)-1 {
 			result.WriteString("\n")
 		}
 	}
@@ -113,37 +302,141 @@ func (tb *TerminalBuffer) CursorPosition() (int, int) {
 	return tb.cursor.X, tb.cursor.Y
 }
 
-// Resize resizes the terminal buffer
+// SetAmbiguousWidth sets the display width of East Asian Ambiguous characters
+// printed from now on
+func (tb *TerminalBuffer) SetAmbiguousWidth(policy AmbiguousWidth) {
+	tb.ambiguousWidth = policy
+}
+
+// SetBackgroundColorErase enables or disables background color erase. When
+// enabled, erased and inserted cells take the current background color;
+// otherwise they have default styles.
+func (tb *TerminalBuffer) SetBackgroundColorErase(enabled bool) {
+	tb.backgroundColorErase = enabled
+}
+
+// BackgroundColorErase reports whether background color erase is enabled
+func (tb *TerminalBuffer) BackgroundColorErase() bool {
+	return tb.backgroundColorErase
+}
+
+// SetResizeHandler sets a function that is called with the new size when the
+// application resizes the page (DECCOLM, DECSCPP, DECSLPP). Embedders use it
+// to resize their window or pseudo terminal; nil removes the handler.
+func (tb *TerminalBuffer) SetResizeHandler(handler func(width, height int)) {
+	tb.resizeHandler = handler
+}
+
+// SetPageResizeAllowed sets whether DECSLPP (CSI Pn t, Pn >= 24) may change
+// the number of lines, up to maxPageLines. It is disabled by default because
+// captured output could otherwise make the buffer arbitrarily large.
+func (tb *TerminalBuffer) SetPageResizeAllowed(allowed bool) {
+	tb.pageResizeAllowed = allowed
+}
+
+// IsAlternateScreen reports whether the alternate screen is active
+func (tb *TerminalBuffer) IsAlternateScreen() bool {
+	return tb.altScreen
+}
+
+// CursorShape returns the current cursor shape
+func (tb *TerminalBuffer) CursorShape() CursorShape {
+	return tb.cursor.Shape
+}
+
+// CursorVisible reports whether the cursor is visible (DECTCEM)
+func (tb *TerminalBuffer) CursorVisible() bool {
+	return !tb.cursor.IsHidden
+}
+
+// CursorBlinking reports whether the cursor blinks
+func (tb *TerminalBuffer) CursorBlinking() bool {
+	return tb.cursor.IsBlinking
+}
+
+// Notifications returns the desktop notifications received so far
+func (tb *TerminalBuffer) Notifications() []govte.Notification {
+	result := make([]govte.Notification, len(tb.notifications))
+	copy(result, tb.notifications)
+	return result
+}
+
+// ClearNotifications discards the recorded desktop notifications
+func (tb *TerminalBuffer) ClearNotifications() {
+	tb.notifications = nil
+}
+
+// Progress returns the latest reported progress state and percentage
+func (tb *TerminalBuffer) Progress() (govte.ProgressState, int) {
+	return tb.progressState, tb.progressPercent
+}
+
+// Resize resizes the terminal buffer. Soft-wrapped lines on the primary screen
+// and in the scrollback history are rewrapped at the new width.
 func (tb *TerminalBuffer) Resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
 	tb.width = width
 	tb.height = height
+	tb.scrollRegion = nil
+	tb.leftMargin, tb.rightMargin = 0, width-1
+	tb.tabStops = resizeTabStops(tb.tabStops, width)
+
+	cursor := &reflowCursor{X: tb.cursor.X, Y: tb.cursor.Y, WrapPending: tb.cursor.WrapPending}
+	if tb.altScreen {
+		// Full-screen applications redraw the alternate screen themselves;
+		// the primary screen keeps the cursor position saved when it was left
+		tb.viewport = resizeRows(tb.viewport, width, height)
+		var saved *reflowCursor
+		if tb.inactiveSavedCursor != nil {
+			saved = &reflowCursor{X: tb.inactiveSavedCursor.X, Y: tb.inactiveSavedCursor.Y}
+		}
+		tb.inactive = tb.reflowPrimary(tb.inactive, saved, width, height)
+		if saved != nil {
+			tb.inactiveSavedCursor.X, tb.inactiveSavedCursor.Y = saved.X, saved.Y
+		}
+		cursor.WrapPending = false
+	} else {
+		tb.viewport = tb.reflowPrimary(tb.viewport, cursor, width, height)
+		tb.inactive = resizeRows(tb.inactive, width, height)
+	}
 
+	tb.cursor.Goto(min(cursor.X, width-1), min(cursor.Y, height-1))
+	tb.cursor.WrapPending = cursor.WrapPending && tb.autowrap
+}
+
+// resizeRows resizes a screen's rows to the given dimensions
+func resizeRows(rows []Row, width, height int) []Row {
 	// Resize existing rows
-	for i := range tb.viewport {
-		tb.viewport[i].EnsureWidth(width)
-		if tb.viewport[i].Len() > width {
-			tb.viewport[i].Truncate(width)
+	for i := range rows {
+		rows[i].EnsureWidth(width)
+		if rows[i].Len() > width {
+			rows[i].Truncate(width)
 		}
 	}
 
 	// Add or remove rows as needed
-	if len(tb.viewport) < height {
+	if len(rows) < height {
 		// Add new rows
-		for len(tb.viewport) < height {
-			tb.viewport = append(tb.viewport, NewRowWithWidth(width))
+		for len(rows) < height {
+			rows = append(rows, NewRowWithWidth(width))
 		}
-	} else if len(tb.viewport) > height {
+	} else if len(rows) > height {
 		// Remove excess rows
-		tb.viewport = tb.viewport[:height]
+		rows = rows[:height]
 	}
 
-	// Ensure cursor is within bounds
-	if tb.cursor.X >= width {
-		tb.cursor.X = width - 1
-	}
-	if tb.cursor.Y >= height {
-		tb.cursor.Y = height - 1
+	return rows
+}
+
+// blankRows creates a screen of empty rows
+func blankRows(width, height int) []Row {
+	rows := make([]Row, height)
+	for i := range rows {
+		rows[i] = NewRowWithWidth(width)
 	}
+	return rows
 }
 
 // === Performer interface implementation ===
@@ -151,26 +444,156 @@ func (tb *TerminalBuffer) Resize(width, height int) {
 // Print handles printable characters
 func (tb *TerminalBuffer) Print(c rune) {
 	tb.ensureCursorInBounds()
+	if tb.cursor.Y >= len(tb.viewport) {
+		return
+	}
+	tb.viewport[tb.cursor.Y].EnsureWidth(tb.width)
+	c = tb.mapCharset(c)
+
+	// Marks and joined runes extend the grapheme cluster in the previous cell
+	if previous, x := tb.previousCell(); previous != nil && joinsCluster(previous, c) {
+		tb.extendCluster(previous, x, c)
+		return
+	}
+
+	width := RuneWidth(c, tb.ambiguousWidth)
+	if width == 0 || width > tb.width {
+		return
+	}
+
+	// A character printed after the last column wraps to the next line
+	if tb.cursor.WrapPending && tb.autowrap {
+		tb.wrapLine()
+	}
+
+	// A wide character that does not fit on the line wraps to the next one,
+	// or overwrites the last two columns when autowrap is disabled
+	if width == 2 && tb.cursor.X == tb.rightEdge() {
+		if tb.autowrap {
+			tb.viewport[tb.cursor.Y].ClearWideChar(tb.cursor.X)
+			tb.viewport[tb.cursor.Y].Set(tb.cursor.X, EmptyTerminalCharacter())
+			tb.viewport[tb.cursor.Y].wrapPadding = true
+			tb.wrapLine()
+		} else {
+			tb.cursor.MoveLeft(1)
+		}
+	}
+
+	// In insert mode the rest of the line moves right; characters pushed past
+	// the right margin are lost
+	if tb.insertMode {
+		tb.viewport[tb.cursor.Y].InsertChars(tb.cursor.X, tb.rightEdge()+1, width, EmptyTerminalCharacter())
+	}
 
 	// Create character with current styles
 	char := NewStyledTerminalCharacter(c, tb.currentStyles)
+	char.Width = width
+
+	// Place the character, blanking any wide character it overwrites
+	row := &tb.viewport[tb.cursor.Y]
+	for x := tb.cursor.X; x < tb.cursor.X+width; x++ {
+		row.ClearWideChar(x)
+	}
+	row.Set(tb.cursor.X, char)
+	tb.lastPrinted = &c
+	tb.advanceCursor(width)
+}
 
-	// Ensure the current row has enough width
-	if tb.cursor.Y < len(tb.viewport) {
-		tb.viewport[tb.cursor.Y].EnsureWidth(tb.width)
+// advanceCursor moves the cursor past a printed character; in the last column
+// the cursor stays put and the wrap is deferred until the next character
+func (tb *TerminalBuffer) advanceCursor(width int) {
+	edge := tb.rightEdge()
+	if tb.cursor.X+width <= edge {
+		tb.cursor.MoveRight(width)
+		return
+	}
+	tb.cursor.Goto(edge, tb.cursor.Y)
+	tb.cursor.WrapPending = tb.autowrap
+}
 
-		// Place the character
-		if tb.cursor.X < tb.width {
-			tb.viewport[tb.cursor.Y].Set(tb.cursor.X, char)
-			tb.cursor.MoveRight(char.Width)
+// rightEdge returns the column where printing wraps: the right margin when the
+// cursor is inside it, otherwise the last column
+func (tb *TerminalBuffer) rightEdge() int {
+	_, right := tb.horizontalBounds()
+	if tb.cursor.X <= right {
+		return min(right, tb.lastColumn())
+	}
+	return tb.lastColumn()
+}
 
-			// Handle line wrapping
-			if tb.cursor.X >= tb.width {
-				tb.cursor.CarriageReturn()
-				tb.cursor.LineFeed()
-				tb.ensureCursorInBounds()
-			}
-		}
+// lastColumn returns the last column of the cursor row, which holds half as
+// many characters when it is double width
+func (tb *TerminalBuffer) lastColumn() int {
+	if tb.cursor.Y >= 0 && tb.cursor.Y < len(tb.viewport) && tb.viewport[tb.cursor.Y].LineSize.IsDoubleWidth() {
+		return max(0, tb.width/2-1)
+	}
+	return tb.width - 1
+}
+
+// wrapLine moves the cursor to the start of the next line and marks that line
+// as the continuation of a soft-wrapped line
+func (tb *TerminalBuffer) wrapLine() {
+	y := tb.cursor.Y
+	_, bottom := tb.scrollBounds()
+
+	tb.carriageReturn()
+	tb.index()
+
+	// Only lines spanning the full width are joined by copy and reflow
+	if left, right := tb.horizontalBounds(); left > 0 || right < tb.width-1 {
+		return
+	}
+	if tb.cursor.Y != y || y == bottom {
+		tb.viewport[tb.cursor.Y].IsCanonical = false
+	}
+}
+
+// previousCell returns the cell written before the cursor and its column
+func (tb *TerminalBuffer) previousCell() (*TerminalCharacter, int) {
+	row := &tb.viewport[tb.cursor.Y]
+	x := tb.cursor.X - 1
+	if tb.cursor.WrapPending {
+		x = tb.cursor.X
+	}
+	if cell := row.Get(x); cell != nil && cell.IsWideCharSpacer() {
+		x--
+	}
+	return row.Get(x), x
+}
+
+// joinsCluster reports whether c continues the grapheme cluster in cell.
+// Nothing joins an empty cell.
+func joinsCluster(cell *TerminalCharacter, c rune) bool {
+	switch {
+	case cell.Character == ' ' && cell.Combining == "":
+		return false
+	case isZeroWidth(c):
+		return true
+	case strings.HasSuffix(cell.Combining, string(zeroWidthJoiner)):
+		return true
+	case isEmojiModifier(c):
+		return isEmojiModifierBase(cell.Character)
+	case isRegionalIndicator(c):
+		// Two regional indicators form a flag
+		return isRegionalIndicator(cell.Character) && cell.Combining == ""
+	}
+	return false
+}
+
+// extendCluster appends c to the grapheme cluster in the cell at column x
+func (tb *TerminalBuffer) extendCluster(cell *TerminalCharacter, x int, c rune) {
+	cell.Combining += string(c)
+
+	// VS16 requests emoji presentation, which is two columns wide
+	if c != variationSelector16 || cell.Width != 1 || x+1 > tb.rightEdge() {
+		return
+	}
+	row := &tb.viewport[tb.cursor.Y]
+	row.ClearWideChar(x + 1)
+	cell.Width = 2
+	row.Set(x+1, wideCharSpacer(cell.Styles))
+	if tb.cursor.X == x+1 && !tb.cursor.WrapPending {
+		tb.advanceCursor(1)
 	}
 }
 
@@ -178,27 +601,22 @@ func (tb *TerminalBuffer) Print(c rune) {
 func (tb *TerminalBuffer) Execute(b byte) {
 	switch b {
 	case 0x07: // BEL - Bell
-		// Terminal bell - could trigger notification
+		tb.ringBell(BellAudible, tb.now())
 	case 0x08: // BS - Backspace
-		tb.cursor.MoveLeft(1)
-		tb.ensureCursorInBounds()
+		tb.moveLeft(1)
 	case 0x09: // HT - Horizontal Tab
-		// Move to next tab stop (every 8 columns)
-		nextTab := ((tb.cursor.X / 8) + 1) * 8
-		if nextTab < tb.width {
-			tb.cursor.X = nextTab
-		} else {
-			tb.cursor.X = tb.width - 1
+		tb.tabForward(1)
+	case 0x0A, 0x0B, 0x0C: // LF, VT, FF - Line Feed
+		if tb.newlineMode {
+			tb.carriageReturn()
 		}
-	case 0x0A: // LF - Line Feed
-		tb.cursor.LineFeed()
-		tb.ensureCursorInBounds()
+		tb.index()
 	case 0x0D: // CR - Carriage Return
-		tb.cursor.CarriageReturn()
+		tb.carriageReturn()
 	case 0x0E: // SO - Shift Out (activate G1 charset)
-		// Character set handling - could be implemented
+		tb.charsets.GL = govte.G1
 	case 0x0F: // SI - Shift In (activate G0 charset)
-		// Character set handling - could be implemented
+		tb.charsets.GL = govte.G0
 	}
 }
 
@@ -232,15 +650,26 @@ func (tb *TerminalBuffer) OscDispatch(params [][]byte, bellTerminated bool) {
 
 	// Handle different OSC commands
 	switch cmd {
-	case "0", "2": // Set window title
+	case "0": // Set icon name and window title
+		if len(params) > 1 {
+			tb.iconName = string(params[1])
+			tb.title = string(params[1])
+		}
+	case "1": // Set icon name
 		if len(params) > 1 {
-			title := string(params[1])
-			tb.title = &title
+			tb.iconName = string(params[1])
 		}
-	case "1": // Set icon name (similar to title)
+	case "2": // Set window title
 		if len(params) > 1 {
-			title := string(params[1])
-			tb.title = &title
+			tb.title = string(params[1])
+		}
+	case "9", "99", "777": // Progress reports and desktop notifications
+		if state, percent, ok := govte.ParseProgress(params); ok {
+			tb.setProgress(state, percent)
+			return
+		}
+		if notification, ok := tb.notificationDecoder.Decode(params); ok {
+			tb.notifications = append(tb.notifications, notification)
 		}
 	}
 }
@@ -257,88 +686,72 @@ func (tb *TerminalBuffer) CsiDispatch(params *govte.Params, intermediates []byte
 		paramGroups = params.Iter()
 	}
 
+	if tb.rectangleDispatch(paramGroups, intermediates, action) {
+		return
+	}
+
 	switch action {
 	case 'H', 'f': // CUP - Cursor Position
-		row, col := 1, 1
-		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 {
-			row = int(paramGroups[0][0])
-		}
-		if len(paramGroups) > 1 && len(paramGroups[1]) > 0 {
-			col = int(paramGroups[1][0])
-		}
-
-		// Convert to 0-based and clamp to screen bounds
-		tb.cursor.X = min(col-1, tb.width-1)
-		tb.cursor.Y = min(row-1, tb.height-1)
-		tb.ensureCursorInBounds()
+		tb.setCursorY(countParam(paramGroups, 0) - 1)
+		tb.setCursorX(countParam(paramGroups, 1) - 1)
 
 	case 'A': // CUU - Cursor Up
 		lines := 1
 		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 && paramGroups[0][0] > 0 {
 			lines = int(paramGroups[0][0])
 		}
-		tb.cursor.MoveUp(lines)
-		tb.ensureCursorInBounds()
+		tb.moveUp(lines)
 
 	case 'B': // CUD - Cursor Down
 		lines := 1
 		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 && paramGroups[0][0] > 0 {
 			lines = int(paramGroups[0][0])
 		}
-		tb.cursor.MoveDown(lines)
-		tb.ensureCursorInBounds()
+		tb.moveDown(lines)
 
 	case 'C': // CUF - Cursor Forward
 		cols := 1
 		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 && paramGroups[0][0] > 0 {
 			cols = int(paramGroups[0][0])
 		}
-		tb.cursor.MoveRight(cols)
-		tb.ensureCursorInBounds()
+		tb.moveRight(cols)
 
 	case 'D': // CUB - Cursor Back
 		cols := 1
 		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 && paramGroups[0][0] > 0 {
 			cols = int(paramGroups[0][0])
 		}
-		tb.cursor.MoveLeft(cols)
-		tb.ensureCursorInBounds()
+		tb.moveLeft(cols)
 
 	case 'G': // CHA - Cursor Horizontal Absolute
-		col := 1
-		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 {
-			col = int(paramGroups[0][0])
-		}
-		tb.cursor.X = min(col-1, tb.width-1)
-		tb.ensureCursorInBounds()
+		tb.setCursorX(countParam(paramGroups, 0) - 1)
 
 	case 'd': // VPA - Vertical Position Absolute
-		row := 1
-		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 {
-			row = int(paramGroups[0][0])
-		}
-		tb.cursor.Y = min(row-1, tb.height-1)
-		tb.ensureCursorInBounds()
+		tb.setCursorY(countParam(paramGroups, 0) - 1)
 
-	case 'J': // ED - Erase in Display
+	case 'J': // ED - Erase in Display, DECSED with '?'
 		mode := 0
 		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 {
 			mode = int(paramGroups[0][0])
 		}
-		tb.eraseInDisplay(mode)
+		tb.eraseInDisplay(mode, isPrivate(intermediates))
 
-	case 'K': // EL - Erase in Line
+	case 'K': // EL - Erase in Line, DECSEL with '?'
 		mode := 0
 		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 {
 			mode = int(paramGroups[0][0])
 		}
-		tb.eraseInLine(mode)
+		tb.eraseInLine(mode, isPrivate(intermediates))
 
 	case 'm': // SGR - Select Graphic Rendition
 		tb.currentStyles.AddStyleFromAnsiParams(paramGroups)
 		tb.cursor.PendingStyles = tb.currentStyles
 
 	case 'r': // DECSTBM - Set Top and Bottom Margins
+		if isPrivate(intermediates) { // XTRESTORE - Restore DEC Private Modes
+			tb.restorePrivateModes(paramGroups)
+			break
+		}
 		top, bottom := 1, tb.height
 		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 {
 			top = int(paramGroups[0][0])
@@ -352,18 +765,32 @@ func (tb *TerminalBuffer) CsiDispatch(params *govte.Params, intermediates []byte
 				top:    top - 1, // Convert to 0-based
 				bottom: bottom - 1,
 			}
+			tb.homeCursor()
 		}
 
-	case 's': // SCOSC - Save Cursor Position
-		saved := tb.cursor.SavePosition()
-		tb.savedCursor = &saved
+	case 's':
+		if isPrivate(intermediates) { // XTSAVE - Save DEC Private Modes
+			tb.savePrivateModes(paramGroups)
+		} else if tb.lrMarginMode { // DECSLRM - Set Left and Right Margins
+			left, right := 1, tb.width
+			if len(paramGroups) > 0 && len(paramGroups[0]) > 0 && paramGroups[0][0] > 0 {
+				left = int(paramGroups[0][0])
+			}
+			if len(paramGroups) > 1 && len(paramGroups[1]) > 0 && paramGroups[1][0] > 0 {
+				right = int(paramGroups[1][0])
+			}
 
-	case 'u': // SCORC - Restore Cursor Position
-		if tb.savedCursor != nil {
-			tb.cursor.RestorePosition(*tb.savedCursor)
-			tb.currentStyles = tb.cursor.PendingStyles
+			if left < right && right <= tb.width {
+				tb.leftMargin, tb.rightMargin = left-1, right-1
+				tb.homeCursor()
+			}
+		} else { // SCOSC - Save Cursor Position
+			tb.saveCursor()
 		}
 
+	case 'u': // SCORC - Restore Cursor Position
+		tb.restoreCursor()
+
 	case 'S': // SU - Scroll Up
 		lines := 1
 		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 {
@@ -377,6 +804,116 @@ func (tb *TerminalBuffer) CsiDispatch(params *govte.Params, intermediates []byte
 			lines = int(paramGroups[0][0])
 		}
 		tb.scrollDown(lines)
+
+	case 'E': // CNL - Cursor Next Line
+		tb.moveDown(countParam(paramGroups, 0))
+		tb.carriageReturn()
+
+	case 'F': // CPL - Cursor Previous Line
+		tb.moveUp(countParam(paramGroups, 0))
+		tb.carriageReturn()
+
+	case '`': // HPA - Horizontal Position Absolute
+		tb.setCursorX(countParam(paramGroups, 0) - 1)
+
+	case 'a': // HPR - Horizontal Position Relative
+		tb.moveRight(countParam(paramGroups, 0))
+
+	case 'e': // VPR - Vertical Position Relative
+		tb.moveDown(countParam(paramGroups, 0))
+
+	case '@': // ICH - Insert Characters
+		tb.ensureCursorInBounds()
+		tb.cursor.WrapPending = false
+		if tb.cursorInHorizontalMargins() {
+			_, right := tb.horizontalBounds()
+			tb.viewport[tb.cursor.Y].InsertChars(tb.cursor.X, right+1, countParam(paramGroups, 0), tb.eraseCharacter())
+		}
+
+	case 'P': // DCH - Delete Characters
+		tb.ensureCursorInBounds()
+		tb.cursor.WrapPending = false
+		if tb.cursorInHorizontalMargins() {
+			_, right := tb.horizontalBounds()
+			tb.viewport[tb.cursor.Y].DeleteChars(tb.cursor.X, right+1, countParam(paramGroups, 0), tb.eraseCharacter())
+		}
+
+	case 'X': // ECH - Erase Characters
+		tb.ensureCursorInBounds()
+		tb.cursor.WrapPending = false
+		count := countParam(paramGroups, 0)
+		tb.viewport[tb.cursor.Y].ReplaceRange(tb.cursor.X, tb.cursor.X+count, tb.eraseCharacter())
+
+	case 'L': // IL - Insert Lines
+		tb.insertLines(countParam(paramGroups, 0))
+
+	case 'M': // DL - Delete Lines
+		tb.deleteLines(countParam(paramGroups, 0))
+
+	case 'I': // CHT - Cursor Horizontal Forward Tabulation
+		tb.tabForward(countParam(paramGroups, 0))
+
+	case 'Z': // CBT - Cursor Backward Tabulation
+		tb.tabBackward(countParam(paramGroups, 0))
+
+	case '|': // DECSCPP - Set Columns Per Page
+		if len(intermediates) == 1 && intermediates[0] == '$' {
+			columns := 80
+			if paramValue(paramGroups, 0) > 80 {
+				columns = 132
+			}
+			tb.resizePage(columns, tb.height)
+		}
+
+	case 't': // Window operations; 24 and above is DECSLPP - Set Lines Per Page
+		if len(intermediates) > 0 {
+			break
+		}
+		op := paramValue(paramGroups, 0)
+		if !tb.titleOperation(op, paramGroups) && op >= 24 && tb.pageResizeAllowed {
+			tb.resizePage(tb.width, min(op, maxPageLines))
+		}
+
+	case 'g': // TBC - Tabulation Clear
+		mode := 0
+		if len(paramGroups) > 0 && len(paramGroups[0]) > 0 {
+			mode = int(paramGroups[0][0])
+		}
+		switch mode {
+		case 0:
+			tb.ensureCursorInBounds()
+			tb.tabStops[tb.cursor.X] = false
+		case 3:
+			for x := range tb.tabStops {
+				tb.tabStops[x] = false
+			}
+		}
+
+	case 'b': // REP - Repeat preceding graphic character
+		if tb.lastPrinted != nil {
+			c := *tb.lastPrinted
+			for count := countParam(paramGroups, 0); count > 0; count-- {
+				tb.Print(c)
+			}
+		}
+
+	case 'h': // SM - Set Mode
+		tb.setModes(paramGroups, intermediates, true)
+
+	case 'l': // RM - Reset Mode
+		tb.setModes(paramGroups, intermediates, false)
+
+	case 'q':
+		if len(intermediates) == 1 && intermediates[0] == ' ' { // DECSCUSR - Set Cursor Style
+			style := 0
+			if len(paramGroups) > 0 && len(paramGroups[0]) > 0 {
+				style = int(paramGroups[0][0])
+			}
+			tb.setCursorStyle(style)
+		}
+		if len(intermediates) == 1 && intermediates[0] == '"' { // DECSCA - Select Character Protection Attribute
+			tb.setProtected(paramValue(paramGroups, 0) == 1)
+		}
 	}
 }
 
@@ -386,168 +923,794 @@ func (tb *TerminalBuffer) EscDispatch(intermediates []byte, ignore bool, b byte)
 		return
 	}
 
+	if len(intermediates) == 1 && intermediates[0] == '#' {
+		tb.lineSizeDispatch(b)
+		return
+	}
+
+	if len(intermediates) > 0 {
+		// SCS - Select Character Set
+		if index, charset, ok := govte.ParseCharsetDesignation(intermediates, b); ok {
+			tb.charsets.Designations[index] = charset
+		}
+		return
+	}
+
 	switch b {
 	case 'D': // IND - Index (move cursor down, scroll if needed)
-		tb.cursor.LineFeed()
-		tb.ensureCursorInBounds()
+		tb.index()
 	case 'M': // RI - Reverse Index (move cursor up, scroll if needed)
-		tb.cursor.MoveUp(1)
-		tb.ensureCursorInBounds()
+		tb.reverseIndex()
 	case '7': // DECSC - Save Cursor
-		saved := tb.cursor.SavePosition()
-		tb.savedCursor = &saved
+		tb.saveCursor()
 	case '8': // DECRC - Restore Cursor
-		if tb.savedCursor != nil {
-			tb.cursor.RestorePosition(*tb.savedCursor)
-			tb.currentStyles = tb.cursor.PendingStyles
-		}
+		tb.restoreCursor()
 	case 'c': // RIS - Reset to Initial State
 		tb.reset()
 	case 'E': // NEL - Next Line
-		tb.cursor.NewLine()
+		tb.carriageReturn()
+		tb.index()
+	case 'H': // HTS - Horizontal Tab Set
 		tb.ensureCursorInBounds()
+		tb.tabStops[tb.cursor.X] = true
+	case 'V': // SPA - Start of Protected Area
+		tb.setProtected(true)
+	case 'W': // EPA - End of Protected Area
+		tb.setProtected(false)
+	case 'N': // SS2 - Single Shift 2
+		tb.singleShift, tb.singleShiftActive = govte.G2, true
+	case 'O': // SS3 - Single Shift 3
+		tb.singleShift, tb.singleShiftActive = govte.G3, true
+	case 'n': // LS2 - Locking Shift 2
+		tb.charsets.GL = govte.G2
+	case 'o': // LS3 - Locking Shift 3
+		tb.charsets.GL = govte.G3
 	}
 }
 
+// lineSizeDispatch handles the ESC # sequences
+func (tb *TerminalBuffer) lineSizeDispatch(b byte) {
+	switch b {
+	case '3': // DECDHL - Double-Height Line, top half
+		tb.setLineSize(LineSizeDoubleHeightTop)
+	case '4': // DECDHL - Double-Height Line, bottom half
+		tb.setLineSize(LineSizeDoubleHeightBottom)
+	case '5': // DECSWL - Single-Width Line
+		tb.setLineSize(LineSizeSingle)
+	case '6': // DECDWL - Double-Width Line
+		tb.setLineSize(LineSizeDoubleWidth)
+	case '8': // DECALN - Screen Alignment Pattern
+		tb.screenAlignment()
+	}
+}
+
+// setLineSize changes the size of the cursor row. The characters in the right
+// half of the row are lost when it becomes double width.
+func (tb *TerminalBuffer) setLineSize(size LineSize) {
+	tb.ensureCursorInBounds()
+	row := &tb.viewport[tb.cursor.Y]
+	row.EnsureWidth(tb.width)
+	if size.IsDoubleWidth() && !row.LineSize.IsDoubleWidth() {
+		row.ReplaceRange(tb.width/2, tb.width, EmptyTerminalCharacter())
+	}
+	row.LineSize = size
+	tb.ensureCursorInBounds()
+}
+
+// screenAlignment fills the screen with 'E', resets the margins and line
+// sizes and moves the cursor home (DECALN)
+func (tb *TerminalBuffer) screenAlignment() {
+	tb.scrollRegion = nil
+	tb.leftMargin, tb.rightMargin = 0, tb.width-1
+
+	fill := NewTerminalCharacter('E')
+	for y := range tb.viewport {
+		row := NewRowWithWidth(tb.width)
+		row.ReplaceRange(0, tb.width, fill)
+		tb.viewport[y] = row
+	}
+	tb.cursor.Goto(0, 0)
+}
+
+// mapCharset translates a printed character through the character set
+// invoked into GL, or the single shifted one for this character only
+func (tb *TerminalBuffer) mapCharset(c rune) rune {
+	index := tb.charsets.GL
+	if tb.singleShiftActive {
+		index = tb.singleShift
+		tb.singleShiftActive = false
+	}
+	return tb.charsets.Map(index, c)
+}
+
 // Helper methods
 
-// ensureCursorInBounds ensures cursor position is within screen bounds
-func (tb *TerminalBuffer) ensureCursorInBounds() {
-	if tb.cursor.X < 0 {
-		tb.cursor.X = 0
+// setModes applies SM/RM parameters; a '?' intermediate selects DEC private modes
+func (tb *TerminalBuffer) setModes(paramGroups [][]uint16, intermediates []byte, enabled bool) {
+	private := isPrivate(intermediates)
+
+	for _, group := range paramGroups {
+		if len(group) == 0 {
+			continue
+		}
+		if private {
+			tb.setPrivateMode(group[0], enabled)
+		} else {
+			tb.setMode(group[0], enabled)
+		}
+	}
+}
+
+// setMode applies a single ANSI mode
+func (tb *TerminalBuffer) setMode(mode uint16, enabled bool) {
+	switch mode {
+	case 4: // IRM - Insert/Replace Mode
+		tb.insertMode = enabled
+	case 20: // LNM - Line Feed/New Line Mode
+		tb.newlineMode = enabled
+	}
+}
+
+// setPrivateMode applies a single DEC private mode
+func (tb *TerminalBuffer) setPrivateMode(mode uint16, enabled bool) {
+	switch mode {
+	case 3: // DECCOLM - 80/132 Column Mode
+		if tb.allowColumnChange {
+			tb.setColumnMode(enabled)
+		}
+	case 5: // DECSCNM - Screen Mode (reverse video)
+		tb.setReverseVideo(enabled)
+	case 40: // Allow 80/132 column switching
+		tb.allowColumnChange = enabled
+	case 6: // DECOM - Origin Mode
+		tb.originMode = enabled
+		tb.homeCursor()
+	case 7: // DECAWM - Autowrap Mode
+		tb.autowrap = enabled
+		if !enabled {
+			tb.cursor.WrapPending = false
+		}
+	case 69: // DECLRMM - Left Right Margin Mode
+		tb.lrMarginMode = enabled
+		if !enabled {
+			tb.leftMargin, tb.rightMargin = 0, tb.width-1
+		}
+	case 12: // xterm blinking cursor
+		tb.cursor.SetBlinking(enabled)
+	case 25: // DECTCEM - Text Cursor Enable Mode
+		if enabled {
+			tb.cursor.Show()
+		} else {
+			tb.cursor.Hide()
+		}
+	case 47: // Alternate screen
+		tb.switchScreen(enabled)
+	case 1047: // Alternate screen, cleared when leaving it
+		if !enabled && tb.altScreen {
+			tb.clearScreen()
+		}
+		tb.switchScreen(enabled)
+	case 1048: // Save/restore cursor
+		if enabled {
+			tb.saveCursor()
+		} else {
+			tb.restoreCursor()
+		}
+	case 1049: // Save cursor and switch to a cleared alternate screen
+		if enabled {
+			if !tb.altScreen {
+				tb.saveCursor()
+				tb.switchScreen(true)
+			}
+			tb.clearScreen()
+		} else {
+			tb.switchScreen(false)
+			tb.restoreCursor()
+		}
+	}
+}
+
+// privateMode returns the state of a DEC private mode and whether the buffer
+// keeps that state
+func (tb *TerminalBuffer) privateMode(mode uint16) (enabled, ok bool) {
+	switch mode {
+	case 3:
+		return tb.width == 132, true
+	case 5:
+		return tb.reverseVideo, true
+	case 6:
+		return tb.originMode, true
+	case 7:
+		return tb.autowrap, true
+	case 12:
+		return tb.cursor.IsBlinking, true
+	case 25:
+		return !tb.cursor.IsHidden, true
+	case 40:
+		return tb.allowColumnChange, true
+	case 47, 1047, 1049:
+		return tb.altScreen, true
+	case 69:
+		return tb.lrMarginMode, true
+	}
+	return false, false
+}
+
+// savePrivateModes handles XTSAVE, saving the state of DEC private modes
+func (tb *TerminalBuffer) savePrivateModes(paramGroups [][]uint16) {
+	for _, group := range paramGroups {
+		if len(group) == 0 {
+			continue
+		}
+		if enabled, ok := tb.privateMode(group[0]); ok {
+			if tb.savedModes == nil {
+				tb.savedModes = make(map[uint16]bool)
+			}
+			tb.savedModes[group[0]] = enabled
+		}
 	}
-	if tb.cursor.X >= tb.width {
-		tb.cursor.X = tb.width - 1
+}
+
+// restorePrivateModes handles XTRESTORE, restoring DEC private modes saved by
+// XTSAVE. Modes already in the saved state are left alone, so that restoring
+// does not clear the screen or home the cursor for nothing.
+func (tb *TerminalBuffer) restorePrivateModes(paramGroups [][]uint16) {
+	for _, group := range paramGroups {
+		if len(group) == 0 {
+			continue
+		}
+		saved, ok := tb.savedModes[group[0]]
+		if current, _ := tb.privateMode(group[0]); ok && saved != current {
+			tb.setPrivateMode(group[0], saved)
+		}
+	}
+}
+
+// setColumnMode switches to 132 or 80 columns; the screen is cleared, the
+// margins are reset and the cursor moves home
+func (tb *TerminalBuffer) setColumnMode(wide bool) {
+	columns := 80
+	if wide {
+		columns = 132
 	}
+	tb.resizePage(columns, tb.height)
+
+	tb.clearScreen()
+	tb.scrollRegion = nil
+	tb.leftMargin, tb.rightMargin = 0, tb.width-1
+	tb.cursor.Goto(0, 0)
+}
+
+// resizePage resizes the buffer on behalf of the application and notifies
+// the resize handler
+func (tb *TerminalBuffer) resizePage(width, height int) {
+	if width == tb.width && height == tb.height {
+		return
+	}
+	tb.Resize(width, height)
+	if tb.resizeHandler != nil {
+		tb.resizeHandler(width, height)
+	}
+}
+
+// switchScreen makes the alternate or the primary screen active
+func (tb *TerminalBuffer) switchScreen(alternate bool) {
+	if tb.altScreen == alternate {
+		return
+	}
+	tb.viewport, tb.inactive = tb.inactive, tb.viewport
+	tb.savedCursor, tb.inactiveSavedCursor = tb.inactiveSavedCursor, tb.savedCursor
+	tb.altScreen = alternate
+}
+
+// clearScreen replaces every row of the active screen with a blank row
+func (tb *TerminalBuffer) clearScreen() {
+	for i := range tb.viewport {
+		tb.viewport[i] = NewRowWithWidth(tb.width)
+	}
+}
+
+// saveCursor saves the cursor position, styles, character sets and origin
+// mode for the active screen
+func (tb *TerminalBuffer) saveCursor() {
+	saved := tb.cursor.SavePosition()
+	saved.Charsets = tb.charsets
+	saved.OriginMode = tb.originMode
+	tb.savedCursor = &saved
+}
+
+// restoreCursor restores the state saved for the active screen. Without a
+// saved state the cursor moves home and the attributes are reset.
+func (tb *TerminalBuffer) restoreCursor() {
+	saved := SavedCursor{Styles: DefaultCharacterStyles()}
+	if tb.savedCursor != nil {
+		saved = *tb.savedCursor
+	}
+
+	tb.cursor.RestorePosition(saved)
+	tb.cursor.X = min(tb.cursor.X, tb.width-1)
+	tb.cursor.Y = min(tb.cursor.Y, tb.height-1)
+	tb.cursor.WrapPending = tb.cursor.WrapPending && tb.autowrap
+	tb.currentStyles = tb.cursor.PendingStyles
+	tb.charsets = saved.Charsets
+	tb.originMode = saved.OriginMode
+}
+
+// setCursorStyle handles DECSCUSR
+func (tb *TerminalBuffer) setCursorStyle(style int) {
+	switch style {
+	case 0, 1: // Blinking block
+		tb.cursor.ChangeShape(CursorShapeBlock)
+		tb.cursor.SetBlinking(true)
+	case 2: // Steady block
+		tb.cursor.ChangeShape(CursorShapeBlock)
+		tb.cursor.SetBlinking(false)
+	case 3: // Blinking underline
+		tb.cursor.ChangeShape(CursorShapeUnderline)
+		tb.cursor.SetBlinking(true)
+	case 4: // Steady underline
+		tb.cursor.ChangeShape(CursorShapeUnderline)
+		tb.cursor.SetBlinking(false)
+	case 5: // Blinking bar
+		tb.cursor.ChangeShape(CursorShapeBeam)
+		tb.cursor.SetBlinking(true)
+	case 6: // Steady bar
+		tb.cursor.ChangeShape(CursorShapeBeam)
+		tb.cursor.SetBlinking(false)
+	}
+}
+
+// isPrivate checks for the '?' marker of DEC private sequences
+func isPrivate(intermediates []byte) bool {
+	return len(intermediates) > 0 && intermediates[0] == '?'
+}
+
+// paramValue returns a parameter, or 0 when it is missing
+func paramValue(paramGroups [][]uint16, index int) int {
+	if index < len(paramGroups) && len(paramGroups[index]) > 0 {
+		return int(paramGroups[index][0])
+	}
+	return 0
+}
+
+// countParam returns a count parameter, treating a missing or zero value as 1
+func countParam(paramGroups [][]uint16, index int) int {
+	if index < len(paramGroups) && len(paramGroups[index]) > 0 && paramGroups[index][0] > 0 {
+		return int(paramGroups[index][0])
+	}
+	return 1
+}
+
+// scrollBounds returns the top and bottom rows of the scroll region (0-based, inclusive)
+func (tb *TerminalBuffer) scrollBounds() (int, int) {
+	if tb.scrollRegion != nil {
+		return tb.scrollRegion.top, tb.scrollRegion.bottom
+	}
+	return 0, tb.height - 1
+}
+
+// index moves the cursor down one line, scrolling the region at the bottom margin
+func (tb *TerminalBuffer) index() {
+	tb.cursor.WrapPending = false
+	_, bottom := tb.scrollBounds()
+	switch {
+	case tb.cursor.Y == bottom:
+		if tb.cursorInHorizontalMargins() {
+			tb.scrollUp(1)
+		}
+	case tb.cursor.Y < tb.height-1:
+		tb.cursor.LineFeed()
+		tb.ensureCursorInBounds()
+	}
+}
+
+// reverseIndex moves the cursor up one line, scrolling the region at the top margin
+func (tb *TerminalBuffer) reverseIndex() {
+	tb.cursor.WrapPending = false
+	top, _ := tb.scrollBounds()
+	switch {
+	case tb.cursor.Y == top:
+		if tb.cursorInHorizontalMargins() {
+			tb.scrollDown(1)
+		}
+	case tb.cursor.Y > 0:
+		tb.cursor.MoveUp(1)
+		tb.ensureCursorInBounds()
+	}
+}
+
+// moveUp moves the cursor up, stopping at the top margin when starting inside the region
+func (tb *TerminalBuffer) moveUp(lines int) {
+	top, _ := tb.scrollBounds()
+	limit := 0
+	if tb.cursor.Y >= top {
+		limit = top
+	}
+	tb.cursor.Goto(tb.cursor.X, max(limit, tb.cursor.Y-lines))
+	tb.ensureCursorInBounds()
+}
+
+// moveDown moves the cursor down, stopping at the bottom margin when starting inside the region
+func (tb *TerminalBuffer) moveDown(lines int) {
+	_, bottom := tb.scrollBounds()
+	limit := tb.height - 1
+	if tb.cursor.Y <= bottom {
+		limit = bottom
+	}
+	tb.cursor.Goto(tb.cursor.X, min(limit, tb.cursor.Y+lines))
+	tb.ensureCursorInBounds()
+}
+
+// insertLines handles IL: blank lines are inserted at the cursor row and
+// lines pushed past the bottom margin are lost
+func (tb *TerminalBuffer) insertLines(lines int) {
+	top, bottom := tb.scrollBounds()
+	if tb.cursor.Y < top || tb.cursor.Y > bottom || !tb.cursorInHorizontalMargins() {
+		return
+	}
+	tb.shiftLinesDown(tb.cursor.Y, bottom, lines)
+	tb.carriageReturn()
+}
+
+// deleteLines handles DL: lines at the cursor row are removed and blank
+// lines are inserted at the bottom margin
+func (tb *TerminalBuffer) deleteLines(lines int) {
+	top, bottom := tb.scrollBounds()
+	if tb.cursor.Y < top || tb.cursor.Y > bottom || !tb.cursorInHorizontalMargins() {
+		return
+	}
+	tb.shiftLinesUp(tb.cursor.Y, bottom, lines)
+	tb.carriageReturn()
+}
+
+// tabForward moves the cursor forward by count tab stops, stopping at the last column
+func (tb *TerminalBuffer) tabForward(count int) {
+	x := tb.cursor.X
+	for ; count > 0 && x < tb.width-1; count-- {
+		x++
+		for x < tb.width-1 && !tb.tabStops[x] {
+			x++
+		}
+	}
+	tb.cursor.Goto(x, tb.cursor.Y)
+}
+
+// tabBackward moves the cursor backward by count tab stops, stopping at the first column
+func (tb *TerminalBuffer) tabBackward(count int) {
+	x := min(tb.cursor.X, tb.width-1)
+	for ; count > 0 && x > 0; count-- {
+		x--
+		for x > 0 && !tb.tabStops[x] {
+			x--
+		}
+	}
+	tb.cursor.Goto(x, tb.cursor.Y)
+}
+
+// defaultTabStops returns tab stops every 8 columns
+func defaultTabStops(width int) []bool {
+	return resizeTabStops(nil, width)
+}
+
+// resizeTabStops resizes the tab stops, adding the default stops every
+// 8 columns to new columns
+func resizeTabStops(tabStops []bool, width int) []bool {
+	resized := make([]bool, width)
+	copy(resized, tabStops)
+	for x := len(tabStops); x < width; x++ {
+		resized[x] = x > 0 && x%8 == 0
+	}
+	return resized
+}
+
+// shiftLinesUp moves rows top..bottom up by n, filling the bottom with blank rows.
+// With left and right margins only the columns between them move.
+func (tb *TerminalBuffer) shiftLinesUp(top, bottom, n int) {
+	if n <= 0 || top > bottom || bottom >= len(tb.viewport) {
+		return
+	}
+	n = min(n, bottom-top+1)
+
+	if !tb.fullWidthMargins() {
+		left, right := tb.horizontalBounds()
+		for y := top; y <= bottom-n; y++ {
+			tb.copyColumns(y, y+n, left, right)
+		}
+		for y := bottom + 1 - n; y <= bottom; y++ {
+			tb.viewport[y].ReplaceRange(left, right+1, tb.eraseCharacter())
+		}
+		return
+	}
+
+	copy(tb.viewport[top:bottom+1-n], tb.viewport[top+n:bottom+1])
+	for y := bottom + 1 - n; y <= bottom; y++ {
+		tb.viewport[y] = tb.blankRow()
+	}
+}
+
+// shiftLinesDown moves rows top..bottom down by n, filling the top with blank rows.
+// With left and right margins only the columns between them move.
+func (tb *TerminalBuffer) shiftLinesDown(top, bottom, n int) {
+	if n <= 0 || top > bottom || bottom >= len(tb.viewport) {
+		return
+	}
+	n = min(n, bottom-top+1)
+
+	if !tb.fullWidthMargins() {
+		left, right := tb.horizontalBounds()
+		for y := bottom; y >= top+n; y-- {
+			tb.copyColumns(y, y-n, left, right)
+		}
+		for y := top; y < top+n; y++ {
+			tb.viewport[y].ReplaceRange(left, right+1, tb.eraseCharacter())
+		}
+		return
+	}
+
+	copy(tb.viewport[top+n:bottom+1], tb.viewport[top:bottom+1-n])
+	for y := top; y < top+n; y++ {
+		tb.viewport[y] = tb.blankRow()
+	}
+}
+
+// copyColumns copies the columns left..right of row src into row dst
+func (tb *TerminalBuffer) copyColumns(dst, src, left, right int) {
+	tb.viewport[dst].EnsureWidth(tb.width)
+	tb.viewport[src].EnsureWidth(tb.width)
+	copy(tb.viewport[dst].Columns[left:right+1], tb.viewport[src].Columns[left:right+1])
+	tb.viewport[dst].repairWideChars()
+}
+
+// horizontalBounds returns the left and right margins (0-based, inclusive)
+func (tb *TerminalBuffer) horizontalBounds() (int, int) {
+	if tb.lrMarginMode {
+		return tb.leftMargin, tb.rightMargin
+	}
+	return 0, tb.width - 1
+}
+
+// fullWidthMargins reports whether the left and right margins span the screen
+func (tb *TerminalBuffer) fullWidthMargins() bool {
+	left, right := tb.horizontalBounds()
+	return left == 0 && right >= tb.width-1
+}
+
+// cursorInHorizontalMargins reports whether the cursor is between the left and right margins
+func (tb *TerminalBuffer) cursorInHorizontalMargins() bool {
+	left, right := tb.horizontalBounds()
+	return tb.cursor.X >= left && tb.cursor.X <= right
+}
+
+// setCursorX moves the cursor to column x, which is relative to and clamped
+// to the left and right margins in origin mode
+func (tb *TerminalBuffer) setCursorX(x int) {
+	left, right := 0, tb.width-1
+	if tb.originMode {
+		left, right = tb.horizontalBounds()
+	}
+	right = min(right, tb.lastColumn())
+	tb.cursor.Goto(max(left, min(left+x, right)), tb.cursor.Y)
+}
+
+// setCursorY moves the cursor to row y, which is relative to and clamped
+// to the scroll region in origin mode
+func (tb *TerminalBuffer) setCursorY(y int) {
+	top, bottom := 0, tb.height-1
+	if tb.originMode {
+		top, bottom = tb.scrollBounds()
+	}
+	tb.cursor.Goto(tb.cursor.X, max(top, min(top+y, bottom)))
+	tb.ensureCursorInBounds()
+}
+
+// homeCursor moves the cursor to the home position, which is the top left
+// margin in origin mode
+func (tb *TerminalBuffer) homeCursor() {
+	tb.setCursorY(0)
+	tb.setCursorX(0)
+}
+
+// carriageReturn moves the cursor to the left margin, or to the first column
+// when the cursor is left of the margin
+func (tb *TerminalBuffer) carriageReturn() {
+	left, _ := tb.horizontalBounds()
+	if tb.originMode || tb.cursor.X >= left {
+		tb.cursor.Goto(left, tb.cursor.Y)
+	} else {
+		tb.cursor.CarriageReturn()
+	}
+}
+
+// moveLeft moves the cursor left, stopping at the left margin when starting inside the margins
+func (tb *TerminalBuffer) moveLeft(cols int) {
+	left, _ := tb.horizontalBounds()
+	limit := 0
+	if tb.cursor.X >= left {
+		limit = left
+	}
+	tb.cursor.Goto(max(limit, min(tb.cursor.X, tb.width-1)-cols), tb.cursor.Y)
+}
+
+// moveRight moves the cursor right, stopping at the right margin when starting inside the margins
+func (tb *TerminalBuffer) moveRight(cols int) {
+	_, right := tb.horizontalBounds()
+	limit := tb.lastColumn()
+	if tb.cursor.X <= right {
+		limit = min(right, limit)
+	}
+	tb.cursor.Goto(min(limit, tb.cursor.X+cols), tb.cursor.Y)
+}
+
+// ensureCursorInBounds ensures cursor position is within screen bounds
+func (tb *TerminalBuffer) ensureCursorInBounds() {
 	if tb.cursor.Y < 0 {
 		tb.cursor.Y = 0
 	}
 	if tb.cursor.Y >= tb.height {
 		tb.cursor.Y = tb.height - 1
 	}
+	if tb.cursor.X < 0 {
+		tb.cursor.X = 0
+	}
+	if last := tb.lastColumn(); tb.cursor.X > last {
+		tb.cursor.X = last
+	}
 }
 
-// eraseInDisplay handles ED command
-func (tb *TerminalBuffer) eraseInDisplay(mode int) {
-	emptyChar := EmptyTerminalCharacter()
+// eraseInDisplay handles ED and, when selective, DECSED which keeps
+// protected characters
+func (tb *TerminalBuffer) eraseInDisplay(mode int, selective bool) {
+	tb.cursor.WrapPending = false
 
 	switch mode {
 	case 0: // Clear from cursor to end of display
 		// Clear from cursor to end of current line
 		if tb.cursor.Y < len(tb.viewport) {
-			for x := tb.cursor.X; x < tb.width; x++ {
-				tb.viewport[tb.cursor.Y].Set(x, emptyChar)
-			}
+			tb.eraseDisplayRow(tb.cursor.Y, tb.cursor.X, tb.width, selective)
 		}
 		// Clear all lines below current line
 		for y := tb.cursor.Y + 1; y < len(tb.viewport); y++ {
-			tb.viewport[y].Clear()
+			tb.eraseDisplayRow(y, 0, tb.width, selective)
 		}
 
 	case 1: // Clear from beginning of display to cursor
 		// Clear all lines above current line
 		for y := 0; y < tb.cursor.Y && y < len(tb.viewport); y++ {
-			tb.viewport[y].Clear()
+			tb.eraseDisplayRow(y, 0, tb.width, selective)
 		}
 		// Clear from beginning of current line to cursor
 		if tb.cursor.Y < len(tb.viewport) {
-			for x := 0; x <= tb.cursor.X && x < tb.width; x++ {
-				tb.viewport[tb.cursor.Y].Set(x, emptyChar)
-			}
+			tb.eraseDisplayRow(tb.cursor.Y, 0, min(tb.cursor.X+1, tb.width), selective)
 		}
 
-	case 2, 3: // Clear entire display
+	case 2: // Clear entire display
 		for y := range tb.viewport {
-			tb.viewport[y].Clear()
+			tb.eraseDisplayRow(y, 0, tb.width, selective)
+		}
+
+	case 3: // Clear scrollback history
+		if !selective {
+			tb.scrollback.Clear()
 		}
 	}
 }
 
-// eraseInLine handles EL command
-func (tb *TerminalBuffer) eraseInLine(mode int) {
+// eraseDisplayRow erases the columns start..end-1 of a row for ED. Rows that
+// are erased completely return to single width.
+func (tb *TerminalBuffer) eraseDisplayRow(y, start, end int, selective bool) {
+	tb.eraseRow(y, start, end, selective)
+	if !selective && start <= 0 && end >= tb.width {
+		tb.viewport[y].LineSize = LineSizeSingle
+	}
+}
+
+// eraseInLine handles EL and, when selective, DECSEL which keeps protected
+// characters
+func (tb *TerminalBuffer) eraseInLine(mode int, selective bool) {
 	if tb.cursor.Y >= len(tb.viewport) {
 		return
 	}
 
-	emptyChar := EmptyTerminalCharacter()
-	row := &tb.viewport[tb.cursor.Y]
+	tb.cursor.WrapPending = false
 
 	switch mode {
 	case 0: // Clear from cursor to end of line
-		for x := tb.cursor.X; x < tb.width; x++ {
-			row.Set(x, emptyChar)
-		}
+		tb.eraseRow(tb.cursor.Y, tb.cursor.X, tb.width, selective)
 
 	case 1: // Clear from beginning of line to cursor
-		for x := 0; x <= tb.cursor.X && x < tb.width; x++ {
-			row.Set(x, emptyChar)
-		}
+		tb.eraseRow(tb.cursor.Y, 0, min(tb.cursor.X+1, tb.width), selective)
 
 	case 2: // Clear entire line
-		row.Clear()
+		tb.eraseRow(tb.cursor.Y, 0, tb.width, selective)
 	}
 }
 
-// scrollUp scrolls the display up by n lines
-func (tb *TerminalBuffer) scrollUp(lines int) {
-	if lines <= 0 {
-		return
+// eraseRow erases the columns start..end-1 of a viewport row. A selective
+// erase keeps protected characters; erasing a whole row otherwise makes it
+// start a new line.
+func (tb *TerminalBuffer) eraseRow(y, start, end int, selective bool) {
+	row := &tb.viewport[y]
+	switch {
+	case selective:
+		row.EraseUnprotected(start, end, tb.eraseCharacter())
+	case start <= 0 && end >= tb.width:
+		tb.clearRow(row)
+	default:
+		row.ReplaceRange(start, end, tb.eraseCharacter())
 	}
+}
 
-	// Determine scroll region
-	top := 0
-	bottom := tb.height - 1
-	if tb.scrollRegion != nil {
-		top = tb.scrollRegion.top
-		bottom = tb.scrollRegion.bottom
+// setProtected sets whether characters printed from now on are protected
+// from selective erase
+func (tb *TerminalBuffer) setProtected(protected bool) {
+	tb.currentStyles.Protected = protected
+	tb.cursor.PendingStyles = tb.currentStyles
+}
+
+// eraseCharacter returns the character that fills erased and inserted cells.
+// With background color erase it takes the current background color.
+func (tb *TerminalBuffer) eraseCharacter() TerminalCharacter {
+	char := EmptyTerminalCharacter()
+	char.Styles.Background = tb.eraseBackground()
+	return char
+}
+
+// eraseBackground returns the background color of erased cells, nil for the
+// default background. A reset background (SGR 49) is the default background.
+func (tb *TerminalBuffer) eraseBackground() *AnsiCode {
+	background := tb.currentStyles.Background
+	if !tb.backgroundColorErase || background == nil || background.Type == AnsiCodeTypeReset {
+		return nil
 	}
+	return background
+}
 
-	// Shift lines up within scroll region
-	for i := 0; i < lines; i++ {
-		if top < bottom {
-			// Remove the top line and add a blank line at the bottom
-			for y := top; y < bottom; y++ {
-				if y+1 < len(tb.viewport) {
-					tb.viewport[y] = tb.viewport[y+1]
-				}
-			}
-			// Add blank line at bottom of scroll region
-			if bottom < len(tb.viewport) {
-				tb.viewport[bottom] = NewRowWithWidth(tb.width)
-			}
+// clearRow fills a row with the erase character and marks it as starting a line
+func (tb *TerminalBuffer) clearRow(row *Row) {
+	row.Clear()
+	if tb.eraseBackground() != nil {
+		row.ReplaceRange(0, len(row.Columns), tb.eraseCharacter())
+	}
+}
+
+// blankRow returns a new row filled with the erase character
+func (tb *TerminalBuffer) blankRow() Row {
+	row := NewRowWithWidth(tb.width)
+	tb.clearRow(&row)
+	return row
+}
+
+// scrollUp scrolls the display up by n lines; rows leaving the top of the
+// screen are saved to the scrollback history
+func (tb *TerminalBuffer) scrollUp(lines int) {
+	top, bottom := tb.scrollBounds()
+	if top == 0 && !tb.altScreen && tb.fullWidthMargins() {
+		for y := 0; y < lines && y <= bottom; y++ {
+			tb.scrollback.Push(tb.viewport[y])
 		}
 	}
+	tb.shiftLinesUp(top, bottom, lines)
 }
 
 // scrollDown scrolls the display down by n lines
 func (tb *TerminalBuffer) scrollDown(lines int) {
-	if lines <= 0 {
-		return
-	}
-
-	// Determine scroll region
-	top := 0
-	bottom := tb.height - 1
-	if tb.scrollRegion != nil {
-		top = tb.scrollRegion.top
-		bottom = tb.scrollRegion.bottom
-	}
+	top, bottom := tb.scrollBounds()
+	tb.shiftLinesDown(top, bottom, lines)
+}
 
-	// Shift lines down within scroll region
-	for i := 0; i < lines; i++ {
-		if top < bottom {
-			// Shift lines down
-			for y := bottom; y > top; y-- {
-				if y-1 >= 0 && y < len(tb.viewport) {
-					tb.viewport[y] = tb.viewport[y-1]
-				}
-			}
-			// Add blank line at top of scroll region
-			if top < len(tb.viewport) {
-				tb.viewport[top] = NewRowWithWidth(tb.width)
-			}
+// setProgress records a progress report
+func (tb *TerminalBuffer) setProgress(state govte.ProgressState, percent int) {
+	switch state {
+	case govte.ProgressHidden, govte.ProgressIndeterminate:
+		percent = 0
+	case govte.ProgressError, govte.ProgressPaused:
+		// Without a value these states keep the previous percentage
+		if percent == 0 {
+			percent = tb.progressPercent
 		}
 	}
+	tb.progressState = state
+	tb.progressPercent = percent
 }
 
 // reset resets the terminal to initial state
@@ -555,11 +1718,30 @@ func (tb *TerminalBuffer) reset() {
 	tb.cursor = NewCursor()
 	tb.currentStyles = DefaultCharacterStyles()
 	tb.savedCursor = nil
+	tb.inactiveSavedCursor = nil
+	tb.charsets = CharsetState{}
+	tb.singleShiftActive = false
 	tb.scrollRegion = nil
-	tb.title = nil
-
-	// Clear all content
-	for i := range tb.viewport {
-		tb.viewport[i] = NewRowWithWidth(tb.width)
-	}
+	tb.lastPrinted = nil
+	tb.autowrap = true
+	tb.insertMode = false
+	tb.newlineMode = false
+	tb.originMode = false
+	tb.attributeExtent = govte.AttributeChangeStream
+	tb.allowColumnChange = false
+	tb.savedModes = nil
+	tb.reverseVideo = false
+	tb.lrMarginMode = false
+	tb.leftMargin, tb.rightMargin = 0, tb.width-1
+	tb.tabStops = defaultTabStops(tb.width)
+	tb.title, tb.iconName = "", ""
+	tb.titleStack, tb.iconStack = nil, nil
+	tb.notificationDecoder.Reset()
+	tb.progressState = govte.ProgressHidden
+	tb.progressPercent = 0
+
+	// Return to the primary screen and clear all content
+	tb.switchScreen(false)
+	tb.clearScreen()
+	tb.inactive = blankRows(tb.width, tb.height)
 }
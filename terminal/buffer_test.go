@@ -0,0 +1,1174 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cliofy/govte"
+	"github.com/stretchr/testify/assert"
+)
+
+// feed parses input into the terminal buffer
+func feed(tb *TerminalBuffer, input string) {
+	parser := govte.NewParser()
+	parser.Advance(tb, []byte(input))
+}
+
+func TestTerminalBufferNotifications(t *testing.T) {
+	tb := NewTerminalBuffer(80, 24)
+
+	feed(tb, "building...\x1b]777;notify;make;build finished\x07\x1b]9;tests passed\x1b\\")
+	feed(tb, "\x1b]99;i=7:d=0;Deploy\x1b\\\x1b]99;i=7:p=body;done\x1b\\")
+
+	assert.Equal(t, []govte.Notification{
+		{Title: "make", Body: "build finished", Urgency: govte.NotificationUrgencyNormal},
+		{Body: "tests passed", Urgency: govte.NotificationUrgencyNormal},
+		{ID: "7", Title: "Deploy", Body: "done", Urgency: govte.NotificationUrgencyNormal},
+	}, tb.Notifications())
+	assert.Equal(t, "building...", tb.GetDisplay())
+
+	tb.ClearNotifications()
+	assert.Empty(t, tb.Notifications())
+}
+
+func TestTerminalBufferProgress(t *testing.T) {
+	tb := NewTerminalBuffer(80, 24)
+
+	state, percent := tb.Progress()
+	assert.Equal(t, govte.ProgressHidden, state)
+	assert.Equal(t, 0, percent)
+
+	feed(tb, "\x1b]9;4;1;40\x07")
+	state, percent = tb.Progress()
+	assert.Equal(t, govte.ProgressNormal, state)
+	assert.Equal(t, 40, percent)
+
+	// Paused without a value keeps the previous percentage
+	feed(tb, "\x1b]9;4;4\x07")
+	state, percent = tb.Progress()
+	assert.Equal(t, govte.ProgressPaused, state)
+	assert.Equal(t, 40, percent)
+
+	feed(tb, "\x1b]9;4;3\x07")
+	state, percent = tb.Progress()
+	assert.Equal(t, govte.ProgressIndeterminate, state)
+	assert.Equal(t, 0, percent)
+
+	feed(tb, "\x1b]9;4;0\x07")
+	state, _ = tb.Progress()
+	assert.Equal(t, govte.ProgressHidden, state)
+	assert.Empty(t, tb.Notifications())
+}
+
+func TestTerminalBufferCursorStyle(t *testing.T) {
+	tb := NewTerminalBuffer(80, 24)
+
+	assert.Equal(t, CursorShapeBlock, tb.CursorShape())
+	assert.True(t, tb.CursorVisible())
+	assert.False(t, tb.CursorBlinking())
+
+	feed(tb, "\x1b[5 q")
+	assert.Equal(t, CursorShapeBeam, tb.CursorShape())
+	assert.True(t, tb.CursorBlinking())
+
+	feed(tb, "\x1b[4 q")
+	assert.Equal(t, CursorShapeUnderline, tb.CursorShape())
+	assert.False(t, tb.CursorBlinking())
+
+	feed(tb, "\x1b[?12h")
+	assert.Equal(t, CursorShapeUnderline, tb.CursorShape())
+	assert.True(t, tb.CursorBlinking())
+
+	feed(tb, "\x1b[?25l")
+	assert.False(t, tb.CursorVisible())
+	feed(tb, "\x1b[?25h")
+	assert.True(t, tb.CursorVisible())
+
+	feed(tb, "\x1b[?25l\x1bc")
+	assert.True(t, tb.CursorVisible())
+	assert.Equal(t, CursorShapeBlock, tb.CursorShape())
+	assert.False(t, tb.CursorBlinking())
+}
+
+// screenText returns the viewport rows with trailing spaces trimmed
+func screenText(tb *TerminalBuffer) string {
+	lines := make([]string, len(tb.viewport))
+	for y := range tb.viewport {
+		lines[y] = rowText(tb, y)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rowText returns the text of a viewport row with trailing spaces trimmed
+func rowText(tb *TerminalBuffer, y int) string {
+	return strings.TrimRight(tb.viewport[y].ToString(), " ")
+}
+
+func TestTerminalBufferLineFeedScrolls(t *testing.T) {
+	tb := NewTerminalBuffer(5, 3)
+	feed(tb, "a\r\nb\r\nc\r\nd\r\ne")
+	assert.Equal(t, "c\nd\ne", screenText(tb))
+
+	// With a scroll region only the region scrolls
+	tb = NewTerminalBuffer(5, 4)
+	feed(tb, "top\r\n1\r\n2\r\nbot\x1b[2;3r\x1b[3;1H\nX")
+	assert.Equal(t, "top\n2\nX\nbot", screenText(tb))
+
+	// Reverse index at the top margin scrolls down
+	tb = NewTerminalBuffer(5, 3)
+	feed(tb, "a\r\nb\r\nc\x1b[H\x1bMz")
+	assert.Equal(t, "z\na\nb", screenText(tb))
+}
+
+func TestTerminalBufferEditingCharacters(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"ICH", "abcdef\x1b[3G\x1b[2@", "ab  cdef"},
+		{"ICH drops overflow", "abcdefghij\x1b[1;1H\x1b[3@", "   abcdefg"},
+		{"DCH", "abcdef\x1b[2G\x1b[2P", "adef"},
+		{"DCH default", "abcdef\x1b[1G\x1b[P", "bcdef"},
+		{"ECH", "abcdef\x1b[2G\x1b[3X", "a   ef"},
+		{"ECH past end", "abcdef\x1b[5G\x1b[20X", "abcd"},
+		{"REP", "ab\x1b[3b", "abbbb"},
+		{"REP without character", "\x1b[3bx", "x"},
+		{"HPA", "abc\x1b[6`x", "abc  x"},
+		{"HPR", "abc\x1b[2ax", "abc  x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := NewTerminalBuffer(10, 3)
+			feed(tb, tt.input)
+			assert.Equal(t, tt.expected, rowText(tb, 0))
+		})
+	}
+}
+
+func TestTerminalBufferInsertDeleteLines(t *testing.T) {
+	tb := NewTerminalBuffer(5, 5)
+	feed(tb, "1\r\n2\r\n3\r\n4\r\n5\x1b[2;1H\x1b[3CX\x1b[2L")
+	assert.Equal(t, "1\n\n\n2  X\n3", screenText(tb))
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 1, y)
+
+	feed(tb, "\x1b[M")
+	assert.Equal(t, "1\n\n2  X\n3\n", screenText(tb))
+
+	// Lines outside the scroll region are untouched
+	tb = NewTerminalBuffer(5, 5)
+	feed(tb, "1\r\n2\r\n3\r\n4\r\n5\x1b[2;4r\x1b[3;1H\x1b[L")
+	assert.Equal(t, "1\n2\n\n3\n5", screenText(tb))
+	feed(tb, "\x1b[2;1H\x1b[5M")
+	assert.Equal(t, "1\n\n\n\n5", screenText(tb))
+
+	// IL outside the scroll region is ignored
+	feed(tb, "\x1b[5;1H\x1b[L")
+	assert.Equal(t, "1\n\n\n\n5", screenText(tb))
+}
+
+func TestTerminalBufferLineMovement(t *testing.T) {
+	tb := NewTerminalBuffer(10, 10)
+
+	feed(tb, "\x1b[5;5H\x1b[2E")
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 6, y)
+
+	feed(tb, "\x1b[5;5H\x1b[3F")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 1, y)
+
+	feed(tb, "\x1b[2;5H\x1b[3e")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 4, x)
+	assert.Equal(t, 4, y)
+
+	// Vertical movement stops at the scroll region margins
+	feed(tb, "\x1b[3;6r\x1b[4;1H\x1b[20B")
+	_, y = tb.CursorPosition()
+	assert.Equal(t, 5, y)
+	feed(tb, "\x1b[20A")
+	_, y = tb.CursorPosition()
+	assert.Equal(t, 2, y)
+}
+
+func TestTerminalBufferScrollback(t *testing.T) {
+	tb := NewTerminalBuffer(5, 2)
+	feed(tb, "1\r\n2\r\n3\r\n4")
+
+	assert.Equal(t, 2, tb.HistoryLen())
+	row, ok := tb.HistoryRow(0)
+	assert.True(t, ok)
+	assert.Equal(t, "1", strings.TrimRight(row.ToString(), " "))
+	_, ok = tb.HistoryRow(2)
+	assert.False(t, ok)
+	assert.Equal(t, "3    \n4", tb.GetDisplay())
+	assert.Equal(t, "1    \n2    \n3    \n4", tb.GetFullDisplay())
+
+	// Scrolling within a region below the top does not save rows
+	tb = NewTerminalBuffer(5, 3)
+	feed(tb, "a\r\nb\r\nc\x1b[2;3r\x1b[3;1H\n\n")
+	assert.Equal(t, 0, tb.HistoryLen())
+
+	// SU with the full screen region saves rows, IL/DL never do
+	tb = NewTerminalBuffer(5, 3)
+	feed(tb, "a\r\nb\r\nc\x1b[2S\x1b[3;1H\x1b[M")
+	assert.Equal(t, 2, tb.HistoryLen())
+
+	// ED 3 clears the history and leaves the screen alone
+	feed(tb, "\x1b[3J")
+	assert.Equal(t, 0, tb.HistoryLen())
+	assert.Equal(t, "c", tb.GetDisplay())
+}
+
+func TestTerminalBufferScrollbackLimit(t *testing.T) {
+	tb := NewTerminalBuffer(4, 1)
+	tb.SetScrollbackLimit(3, 0)
+	feed(tb, "1\r\n2\r\n3\r\n4\r\n5\r\n6")
+
+	assert.Equal(t, 3, tb.HistoryLen())
+	row, _ := tb.HistoryRow(0)
+	assert.Equal(t, "3", strings.TrimRight(row.ToString(), " "))
+
+	// Each row is 4 bytes, so an 8 byte limit keeps two rows
+	tb.SetScrollbackLimit(0, 8)
+	assert.Equal(t, 2, tb.HistoryLen())
+	row, _ = tb.HistoryRow(0)
+	assert.Equal(t, "4", strings.TrimRight(row.ToString(), " "))
+
+	tb.ClearScrollback()
+	assert.Equal(t, 0, tb.HistoryLen())
+}
+
+func TestTerminalBufferAlternateScreen(t *testing.T) {
+	tb := NewTerminalBuffer(10, 3)
+	feed(tb, "shell\x1b[2;3H")
+
+	// 1049 saves the cursor and enters a cleared alternate screen
+	feed(tb, "\x1b[?1049h")
+	assert.True(t, tb.IsAlternateScreen())
+	assert.Equal(t, "", tb.GetDisplay())
+	feed(tb, "\x1b[Hvim")
+	assert.Equal(t, "vim", rowText(tb, 0))
+
+	feed(tb, "\x1b[?1049l")
+	assert.False(t, tb.IsAlternateScreen())
+	assert.Equal(t, "shell", rowText(tb, 0))
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 2, x)
+	assert.Equal(t, 1, y)
+
+	// 47 keeps the alternate screen contents and the cursor
+	feed(tb, "\x1b[?47h")
+	assert.Equal(t, "vim", rowText(tb, 0))
+	feed(tb, "\x1b[?47l")
+	assert.Equal(t, "shell", rowText(tb, 0))
+
+	// 1047 clears the alternate screen when leaving it
+	feed(tb, "\x1b[?1047h")
+	assert.Equal(t, "vim", rowText(tb, 0))
+	feed(tb, "\x1b[?1047l\x1b[?47h")
+	assert.Equal(t, "", rowText(tb, 0))
+	feed(tb, "\x1b[?47l")
+
+	// 1048 saves and restores the cursor without switching screens
+	feed(tb, "\x1b[3;4H\x1b[?1048h\x1b[H\x1b[?1048l")
+	assert.False(t, tb.IsAlternateScreen())
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 3, x)
+	assert.Equal(t, 2, y)
+}
+
+func TestTerminalBufferAlternateScreenScrollback(t *testing.T) {
+	tb := NewTerminalBuffer(5, 2)
+	feed(tb, "\x1b[?1049h1\r\n2\r\n3\r\n4")
+	assert.Equal(t, 0, tb.HistoryLen())
+
+	feed(tb, "\x1b[?1049l1\r\n2\r\n3")
+	assert.Equal(t, 1, tb.HistoryLen())
+
+	// RIS returns to the primary screen
+	feed(tb, "\x1b[?1049h\x1bc")
+	assert.False(t, tb.IsAlternateScreen())
+}
+
+func TestTerminalBufferAlternateScreenResize(t *testing.T) {
+	tb := NewTerminalBuffer(5, 2)
+	feed(tb, "abc\x1b[?1049h")
+	tb.Resize(8, 4)
+	feed(tb, "\x1b[4;8Hz\x1b[?1049l")
+	assert.Equal(t, "abc", rowText(tb, 0))
+	assert.Len(t, tb.viewport, 4)
+	assert.Equal(t, 8, tb.viewport[3].Len())
+}
+
+func TestTerminalBufferWideCharacters(t *testing.T) {
+	tb := NewTerminalBuffer(10, 3)
+	feed(tb, "a中b")
+	assert.Equal(t, "a中b", rowText(tb, 0))
+	x, _ := tb.CursorPosition()
+	assert.Equal(t, 4, x)
+	assert.Equal(t, 2, tb.viewport[0].Columns[1].Width)
+	assert.True(t, tb.viewport[0].Columns[2].IsWideCharSpacer())
+
+	// Overwriting half of a wide character blanks the other half
+	feed(tb, "\x1b[3Gx")
+	assert.Equal(t, "a x", rowText(tb, 0)[:3])
+	feed(tb, "\x1b[2;1H中文\x1b[2;1Hy")
+	assert.Equal(t, "y 文", rowText(tb, 1))
+
+	// A wide character that does not fit wraps to the next line
+	tb = NewTerminalBuffer(5, 3)
+	feed(tb, "abcd中")
+	assert.Equal(t, "abcd\n中\n", screenText(tb))
+
+	// Deleting characters through a wide character does not leave a spacer behind
+	tb = NewTerminalBuffer(10, 3)
+	feed(tb, "ab中cd\x1b[2G\x1b[2P")
+	assert.Equal(t, "a cd", rowText(tb, 0))
+}
+
+func TestTerminalBufferGraphemeClusters(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		grapheme string
+		width    int
+		cursorX  int
+	}{
+		{"combining mark", "e\u0301", "e\u0301", 1, 1},
+		{"ZWJ sequence", "👨\u200D👩\u200D👧", "👨\u200D👩\u200D👧", 2, 2},
+		{"flag", "🇯🇵", "🇯🇵", 2, 2},
+		{"skin tone", "👍🏽", "👍🏽", 2, 2},
+		{"VS16 widens", "❤\uFE0F", "❤\uFE0F", 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := NewTerminalBuffer(10, 3)
+			feed(tb, tt.input)
+			cell := tb.viewport[0].Columns[0]
+			assert.Equal(t, tt.grapheme, cell.Grapheme())
+			assert.Equal(t, tt.width, cell.Width)
+			x, _ := tb.CursorPosition()
+			assert.Equal(t, tt.cursorX, x)
+			assert.Equal(t, tt.grapheme, rowText(tb, 0))
+		})
+	}
+
+	// Two flags stay separate
+	tb := NewTerminalBuffer(10, 3)
+	feed(tb, "🇯🇵🇫🇷")
+	assert.Equal(t, "🇯🇵", tb.viewport[0].Columns[0].Grapheme())
+	assert.Equal(t, "🇫🇷", tb.viewport[0].Columns[2].Grapheme())
+
+	// Skin tone modifiers only join emoji that take them
+	tb = NewTerminalBuffer(10, 3)
+	feed(tb, "中🏽")
+	assert.Equal(t, "中", tb.viewport[0].Columns[0].Grapheme())
+	assert.Equal(t, "🏽", tb.viewport[0].Columns[2].Grapheme())
+
+	// Marks do not join empty cells
+	tb = NewTerminalBuffer(10, 3)
+	feed(tb, "ab\x1b[2C\u0301x")
+	assert.Equal(t, " ", tb.viewport[0].Columns[3].Grapheme())
+	assert.Equal(t, "ab  x", rowText(tb, 0))
+}
+
+func TestTerminalBufferAmbiguousWidth(t *testing.T) {
+	tb := NewTerminalBuffer(10, 3)
+	feed(tb, "α")
+	x, _ := tb.CursorPosition()
+	assert.Equal(t, 1, x)
+
+	tb = NewTerminalBuffer(10, 3)
+	tb.SetAmbiguousWidth(AmbiguousWide)
+	feed(tb, "α─")
+	x, _ = tb.CursorPosition()
+	assert.Equal(t, 4, x)
+	assert.Equal(t, "α─", rowText(tb, 0))
+}
+
+func TestTerminalBufferPendingWrap(t *testing.T) {
+	// Writing exactly the width then CR LF does not leave a blank line
+	tb := NewTerminalBuffer(5, 3)
+	feed(tb, "abcde\r\nf")
+	assert.Equal(t, "abcde\nf\n", screenText(tb))
+
+	// The cursor stays in the last column until the next character
+	tb = NewTerminalBuffer(5, 3)
+	feed(tb, "abcde")
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 4, x)
+	assert.Equal(t, 0, y)
+	feed(tb, "f")
+	assert.Equal(t, "abcde\nf\n", screenText(tb))
+	assert.True(t, tb.viewport[0].IsCanonical)
+	assert.False(t, tb.viewport[1].IsCanonical)
+
+	// Cursor movement cancels the pending wrap, SGR does not
+	tb = NewTerminalBuffer(5, 3)
+	feed(tb, "abcde\x1b[1m\x1b[0mf")
+	assert.Equal(t, "abcde\nf\n", screenText(tb))
+	tb = NewTerminalBuffer(5, 3)
+	feed(tb, "abcde\x1b[5Gf")
+	assert.Equal(t, "abcdf\n\n", screenText(tb))
+
+	// Combining marks attach to the last column while the wrap is pending
+	tb = NewTerminalBuffer(5, 3)
+	feed(tb, "abcde\u0301")
+	assert.Equal(t, "e\u0301", tb.viewport[0].Columns[4].Grapheme())
+}
+
+func TestTerminalBufferAutowrapMode(t *testing.T) {
+	tb := NewTerminalBuffer(5, 3)
+	feed(tb, "\x1b[?7labcdefg")
+	assert.Equal(t, "abcdg\n\n", screenText(tb))
+
+	feed(tb, "\x1b[?7h\r\nabcdefg")
+	assert.Equal(t, "abcdg\nabcde\nfg", screenText(tb))
+
+	// RIS enables autowrap again
+	tb = NewTerminalBuffer(5, 3)
+	feed(tb, "\x1b[?7l\x1bcabcdefg")
+	assert.Equal(t, "abcde\nfg\n", screenText(tb))
+}
+
+func TestTerminalBufferLogicalLines(t *testing.T) {
+	tb := NewTerminalBuffer(5, 3)
+	feed(tb, "hello world\r\nok\r\nabcdefghijkl")
+
+	// The first rows scrolled into the history are joined with the screen
+	assert.Equal(t, []string{"hello world", "ok", "abcdefghijkl"}, tb.LogicalLines())
+
+	// Clearing a row makes it start a new line
+	feed(tb, "\x1b[2K")
+	assert.True(t, tb.viewport[2].IsCanonical)
+}
+
+func TestTerminalBufferResizeReflow(t *testing.T) {
+	tb := NewTerminalBuffer(10, 4)
+	feed(tb, "abcdefghijkl\r\nxy")
+
+	// Narrowing rewraps the long line
+	tb.Resize(5, 4)
+	assert.Equal(t, "abcde\nfghij\nkl\nxy", screenText(tb))
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 2, x)
+	assert.Equal(t, 3, y)
+
+	// Widening again restores the original lines
+	tb.Resize(10, 4)
+	assert.Equal(t, "abcdefghij\nkl\nxy\n", screenText(tb))
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 2, x)
+	assert.Equal(t, 2, y)
+
+	tb.Resize(20, 4)
+	assert.Equal(t, "abcdefghijkl\nxy\n\n", screenText(tb))
+	assert.Equal(t, []string{"abcdefghijkl", "xy"}, tb.LogicalLines())
+}
+
+func TestTerminalBufferResizeReflowScrollback(t *testing.T) {
+	tb := NewTerminalBuffer(6, 2)
+	feed(tb, "one two three\r\nend")
+	assert.Equal(t, 2, tb.HistoryLen())
+
+	// Widening pulls the rewrapped history back onto the screen
+	tb.Resize(20, 3)
+	assert.Equal(t, 0, tb.HistoryLen())
+	assert.Equal(t, "one two three\nend\n", screenText(tb))
+
+	// Shrinking pushes rows that no longer fit into the history
+	tb.Resize(4, 3)
+	assert.Equal(t, []string{"one two three", "end"}, tb.LogicalLines())
+	assert.Equal(t, "thre\ne\nend", screenText(tb))
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 3, x)
+	assert.Equal(t, 2, y)
+}
+
+func TestTerminalBufferResizeReflowCursorAboveOverflow(t *testing.T) {
+	tb := NewTerminalBuffer(6, 3)
+	feed(tb, "aaaaaa\r\nbbbbbb\r\ncccccc\x1b[H")
+
+	// The rows below the cursor stay on screen and the cursor moves down
+	tb.Resize(3, 3)
+	assert.Equal(t, "bbb\nccc\nccc", screenText(tb))
+	assert.Equal(t, 3, tb.HistoryLen())
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+
+	tb.Resize(6, 3)
+	assert.Equal(t, []string{"aaaaaa", "bbbbbb", "cccccc"}, tb.LogicalLines())
+	assert.Equal(t, "aaaaaa\nbbbbbb\ncccccc", screenText(tb))
+}
+
+func TestTerminalBufferResizeReflowWideCharacters(t *testing.T) {
+	tb := NewTerminalBuffer(6, 3)
+	feed(tb, "ab中文字")
+	assert.Equal(t, "ab中文\n字\n", screenText(tb))
+
+	// A wide character that no longer fits moves to the next row whole
+	tb.Resize(5, 3)
+	assert.Equal(t, "ab中\n文字\n", screenText(tb))
+	assert.True(t, tb.viewport[0].wrapPadding)
+
+	// The padding column is not joined into the line
+	tb.Resize(8, 3)
+	assert.Equal(t, "ab中文字\n\n", screenText(tb))
+	assert.Equal(t, []string{"ab中文字"}, tb.LogicalLines())
+}
+
+func TestTerminalBufferResizePendingWrap(t *testing.T) {
+	tb := NewTerminalBuffer(5, 3)
+	feed(tb, "abcde")
+	tb.Resize(10, 3)
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 5, x)
+	assert.Equal(t, 0, y)
+	feed(tb, "f")
+	assert.Equal(t, "abcdef\n\n", screenText(tb))
+}
+
+func TestTerminalBufferResizeAlternateScreen(t *testing.T) {
+	tb := NewTerminalBuffer(10, 3)
+	feed(tb, "abcdefghij\x1b[?1049h\x1b[Hfull")
+	tb.Resize(5, 3)
+	assert.Equal(t, "full", rowText(tb, 0))
+
+	feed(tb, "\x1b[?1049l")
+	assert.Equal(t, "abcde\nfghij\n", screenText(tb))
+}
+
+func TestTerminalBufferTabStops(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{"default stops", "\t", 8},
+		{"second stop", "\t\t", 16},
+		{"last column", "\t\t\t\t", 19},
+		{"HTS", "\x1b[4G\x1bH\r\t", 3},
+		{"TBC current", "\x1b[9G\x1b[g\r\t", 16},
+		{"TBC all", "\x1b[3g\r\t", 19},
+		{"CHT", "\x1b[2I", 16},
+		{"CBT", "\x1b[18G\x1b[2Z", 8},
+		{"CBT stops at first column", "\x1b[10G\x1b[5Z", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := NewTerminalBuffer(20, 3)
+			feed(tb, tt.input)
+			x, _ := tb.CursorPosition()
+			assert.Equal(t, tt.expected, x)
+		})
+	}
+}
+
+func TestTerminalBufferTabStopsResetAndResize(t *testing.T) {
+	tb := NewTerminalBuffer(20, 3)
+	feed(tb, "\x1b[3g\x1b[5G\x1bH")
+
+	// New columns get the default stops, existing ones are kept
+	tb.Resize(30, 3)
+	feed(tb, "\x1b[H\t")
+	x, _ := tb.CursorPosition()
+	assert.Equal(t, 4, x)
+	feed(tb, "\t")
+	x, _ = tb.CursorPosition()
+	assert.Equal(t, 24, x)
+
+	// RIS restores the default stops
+	feed(tb, "\x1bc\t")
+	x, _ = tb.CursorPosition()
+	assert.Equal(t, 8, x)
+}
+
+func TestTerminalBufferOriginMode(t *testing.T) {
+	tb := NewTerminalBuffer(10, 10)
+
+	// DECOM homes the cursor to the top margin
+	feed(tb, "\x1b[3;6r\x1b[?6h")
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 2, y)
+
+	// CUP is relative to and clamped to the margins
+	feed(tb, "\x1b[2;4H")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 3, x)
+	assert.Equal(t, 3, y)
+	feed(tb, "\x1b[20;1H")
+	_, y = tb.CursorPosition()
+	assert.Equal(t, 5, y)
+	feed(tb, "\x1b[1d")
+	_, y = tb.CursorPosition()
+	assert.Equal(t, 2, y)
+
+	// Resetting DECOM homes the cursor to the screen origin
+	feed(tb, "\x1b[?6l")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+
+	// DECSTBM homes the cursor
+	feed(tb, "\x1b[5;5H\x1b[2;8r")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+}
+
+func TestTerminalBufferLeftRightMargins(t *testing.T) {
+	// Without DECLRMM, CSI s saves the cursor
+	tb := NewTerminalBuffer(10, 4)
+	feed(tb, "\x1b[2;3H\x1b[3;5s\x1b[H\x1b[u")
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 2, x)
+	assert.Equal(t, 1, y)
+
+	// With DECLRMM, CSI s sets the margins and homes the cursor
+	tb = NewTerminalBuffer(10, 4)
+	feed(tb, "\x1b[?69h\x1b[2;3H\x1b[3;6s")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+
+	// Printing wraps at the right margin to the left margin
+	feed(tb, "\x1b[1;3Habcdef")
+	assert.Equal(t, "  abcd\n  ef\n\n", screenText(tb))
+
+	// DECOM addresses columns relative to the left margin
+	feed(tb, "\x1b[?6h\x1b[1;2HX\x1b[?6l")
+	assert.Equal(t, "  aXcd", rowText(tb, 0))
+
+	// CUF and HPR stop at the right margin
+	feed(tb, "\x1b[2;4H\x1b[9C")
+	x, _ = tb.CursorPosition()
+	assert.Equal(t, 5, x)
+	feed(tb, "\x1b[2;4H\x1b[9a")
+	x, _ = tb.CursorPosition()
+	assert.Equal(t, 5, x)
+}
+
+func TestTerminalBufferMarginScrolling(t *testing.T) {
+	tb := NewTerminalBuffer(6, 3)
+	feed(tb, "abcdef\r\nghijkl\r\nmnopqr")
+	feed(tb, "\x1b[?69h\x1b[2;4s\x1b[S")
+	assert.Equal(t, "ahijef\ngnopkl\nm   qr", screenText(tb))
+	assert.Equal(t, 0, tb.HistoryLen())
+
+	feed(tb, "\x1b[T")
+	assert.Equal(t, "a   ef\nghijkl\nmnopqr", screenText(tb))
+
+	// Line feed at the bottom margin scrolls only inside the margins
+	feed(tb, "\x1b[3;2H\n")
+	assert.Equal(t, "ahijef\ngnopkl\nm   qr", screenText(tb))
+
+	// IL, DL, ICH and DCH stay within the margins
+	feed(tb, "\x1b[1;2H\x1b[L")
+	assert.Equal(t, "a   ef\nghijkl\nmnopqr", screenText(tb))
+	feed(tb, "\x1b[2;2H\x1b[M")
+	assert.Equal(t, "a   ef\ngnopkl\nm   qr", screenText(tb))
+	feed(tb, "\x1b[2;2H\x1b[@")
+	assert.Equal(t, "g nokl", rowText(tb, 1))
+	feed(tb, "\x1b[2P")
+	assert.Equal(t, "go  kl", rowText(tb, 1))
+
+	// Outside the margins IL and ICH are ignored
+	feed(tb, "\x1b[2;6H\x1b[@\x1b[L")
+	assert.Equal(t, "a   ef\ngo  kl\nm   qr", screenText(tb))
+
+	// Resetting DECLRMM restores full width margins
+	feed(tb, "\x1b[?69l\x1b[1;1H\x1b[@")
+	assert.Equal(t, " a   e", rowText(tb, 0))
+}
+
+func TestTerminalBufferSaveRestoreCursor(t *testing.T) {
+	tb := NewTerminalBuffer(10, 5)
+
+	// DECSC saves the pending wrap, origin mode and character sets
+	feed(tb, "\x1b[2;4r\x1b[?6h\x1b[1;7H\x1b[1mabcd")
+	tb.charsets = CharsetState{GL: govte.G1}
+	tb.charsets.Designations[govte.G1] = govte.StandardCharsetSpecialLineDrawing
+	feed(tb, "\x1b7")
+	feed(tb, "\x1b[?6l\x1b[0m\x1b[5;1H")
+	tb.charsets = CharsetState{}
+
+	feed(tb, "\x1b8")
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 9, x)
+	assert.Equal(t, 1, y)
+	assert.True(t, tb.cursor.WrapPending)
+	assert.True(t, tb.originMode)
+	assert.NotNil(t, tb.currentStyles.Bold)
+	assert.Equal(t, govte.G1, tb.charsets.GL)
+	assert.Equal(t, govte.StandardCharsetSpecialLineDrawing, tb.charsets.Designations[govte.G1])
+
+	// DECRC without a saved state homes the cursor and resets attributes
+	tb = NewTerminalBuffer(10, 5)
+	feed(tb, "\x1b[?6h\x1b[1m\x1b[3;3H\x1b8")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+	assert.False(t, tb.originMode)
+	assert.Nil(t, tb.currentStyles.Bold)
+}
+
+func TestTerminalBufferSaveCursorPerScreen(t *testing.T) {
+	tb := NewTerminalBuffer(10, 5)
+	feed(tb, "\x1b[2;2H\x1b7\x1b[?47h\x1b[4;4H\x1b7\x1b[?47l\x1b8")
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 1, x)
+	assert.Equal(t, 1, y)
+
+	feed(tb, "\x1b[?47h\x1b[H\x1b8")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 3, x)
+	assert.Equal(t, 3, y)
+
+	// The alternate screen has no saved cursor until DECSC runs on it
+	tb = NewTerminalBuffer(10, 5)
+	feed(tb, "\x1b[2;2H\x1b7\x1b[?47h\x1b[4;4H\x1b8")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+}
+
+func TestTerminalBufferCharsets(t *testing.T) {
+	tb := NewTerminalBuffer(10, 3)
+
+	// G0 designated as line drawing
+	feed(tb, "\x1b(0lqk\x1b(Bq")
+	assert.Equal(t, "┌─┐q", rowText(tb, 0))
+
+	// SO and SI switch between G0 and G1
+	feed(tb, "\r\n\x1b)0q\x0eq\x0fq")
+	assert.Equal(t, "q─q", rowText(tb, 1))
+
+	// Single shifts apply to the next character only, locking shifts stay
+	feed(tb, "\r\n\x1b*0\x1b+0x\x1bNx\x1bOxx\x1bnx\x1b+B\x1box")
+	assert.Equal(t, "x││x│x", rowText(tb, 2))
+}
+
+func TestTerminalBufferNationalCharsets(t *testing.T) {
+	tb := NewTerminalBuffer(20, 2)
+	feed(tb, "\x1b(A#5\x1b(K{}\x1b(B\r\n\x1b-A\x0eA\x0f\x1b*%5\x1bN1")
+	assert.Equal(t, "£5äü", rowText(tb, 0))
+	assert.Equal(t, "Á±", rowText(tb, 1))
+}
+
+func TestTerminalBufferBackgroundColorErase(t *testing.T) {
+	tb := NewTerminalBuffer(6, 3)
+	feed(tb, "abcdef\r\nghijkl\x1b[44m\x1b[2J")
+	for y := range tb.viewport {
+		for x := range tb.viewport[y].Columns {
+			assert.NotNil(t, tb.viewport[y].Columns[x].Styles.Background, "cell %d,%d", x, y)
+		}
+	}
+
+	// EL, ECH, ICH and scrolling use the background color at the time
+	tb = NewTerminalBuffer(6, 3)
+	feed(tb, "abcdef\x1b[41m\x1b[1;5H\x1b[K\x1b[1;1H\x1b[X\x1b[1;2H\x1b[@")
+	assert.Equal(t, "  bcd", rowText(tb, 0))
+	row := tb.viewport[0].Columns
+	assert.NotNil(t, row[0].Styles.Background)
+	assert.NotNil(t, row[1].Styles.Background)
+	assert.Nil(t, row[2].Styles.Background)
+	assert.NotNil(t, row[5].Styles.Background)
+
+	feed(tb, "\x1b[S")
+	assert.NotNil(t, tb.viewport[2].Columns[0].Styles.Background)
+	feed(tb, "\x1b[0m\x1b[T")
+	assert.Nil(t, tb.viewport[0].Columns[0].Styles.Background)
+
+	// Without BCE erased cells have default styles
+	tb = NewTerminalBuffer(6, 3)
+	tb.SetBackgroundColorErase(false)
+	feed(tb, "\x1b[44m\x1b[2J\x1b[K\x1b[L")
+	assert.Nil(t, tb.viewport[0].Columns[0].Styles.Background)
+	assert.Nil(t, tb.viewport[1].Columns[0].Styles.Background)
+
+	// A reset background (SGR 49) erases with the default background
+	tb = NewTerminalBuffer(6, 3)
+	feed(tb, "abc\x1b[44m\x1b[49m\x1b[2J\x1b[S")
+	assert.Nil(t, tb.viewport[0].Columns[0].Styles.Background)
+	assert.Nil(t, tb.viewport[2].Columns[0].Styles.Background)
+	assert.NotContains(t, tb.GetDisplayWithColors(), "\x1b[49m")
+}
+
+func TestTerminalBufferSelectiveErase(t *testing.T) {
+	tb := NewTerminalBuffer(10, 3)
+
+	// DECSCA protects the characters printed while it is set
+	feed(tb, "ab\x1b[1\"qCD\x1b[0\"qef\r\ngh\x1bVIJ\x1bWkl")
+	assert.False(t, tb.currentStyles.Protected)
+
+	// SGR 0 does not change the protection
+	feed(tb, "\x1b[1\"q\x1b[0m")
+	assert.True(t, tb.currentStyles.Protected)
+	feed(tb, "\x1b[0\"q")
+
+	// DECSEL and DECSED erase only unprotected characters
+	feed(tb, "\x1b[1;1H\x1b[?K")
+	assert.Equal(t, "  CD", rowText(tb, 0))
+	feed(tb, "\x1b[?J")
+	assert.Equal(t, "  CD\n  IJ\n", screenText(tb))
+
+	// EL and ED erase protected characters too
+	feed(tb, "\x1b[2;1H\x1b[K")
+	assert.Equal(t, "  CD\n\n", screenText(tb))
+	feed(tb, "\x1b[2J")
+	assert.Equal(t, "\n\n", screenText(tb))
+}
+
+func TestTerminalBufferRectangularAreas(t *testing.T) {
+	tb := NewTerminalBuffer(6, 4)
+	feed(tb, "abcdef\r\nghijkl\r\nmnopqr\r\nstuvwx")
+
+	// DECFRA fills and DECERA erases
+	feed(tb, "\x1b[42;2;2;3;4$x")
+	assert.Equal(t, "abcdef\ng***kl\nm***qr\nstuvwx", screenText(tb))
+	feed(tb, "\x1b[1;5;2$z")
+	assert.Equal(t, "abcd\ng***\nm***qr\nstuvwx", screenText(tb))
+
+	// DECCRA copies overlapping areas
+	feed(tb, "\x1b[1;1;2;3;1;2;2;1$v")
+	assert.Equal(t, "abcd\ngabc\nmg**qr\nstuvwx", screenText(tb))
+
+	// DECSERA keeps protected characters
+	feed(tb, "\x1b[4;1H\x1b[1\"qST\x1b[0\"q\x1b[4;1;4;6${")
+	assert.Equal(t, "ST", rowText(tb, 3))
+
+	// Origin mode makes the coordinates relative to the margins
+	feed(tb, "\x1b[2;3r\x1b[?6h\x1b[46;2;1$x\x1b[?6l")
+	assert.Equal(t, "......", rowText(tb, 2))
+}
+
+func TestTerminalBufferRectangleAttributes(t *testing.T) {
+	isOn := func(code *AnsiCode) bool { return code != nil && code.Type == AnsiCodeTypeOn }
+
+	// The stream extent wraps from the start to the end position
+	tb := NewTerminalBuffer(4, 3)
+	feed(tb, "\x1b[1;3;2;3;1;4$r")
+	assert.False(t, isOn(tb.viewport[0].Columns[1].Styles.Bold))
+	assert.True(t, isOn(tb.viewport[0].Columns[3].Styles.Bold))
+	assert.True(t, isOn(tb.viewport[1].Columns[0].Styles.Underline))
+	assert.False(t, isOn(tb.viewport[1].Columns[3].Styles.Bold))
+
+	// The rectangle extent changes only the columns inside
+	tb = NewTerminalBuffer(4, 3)
+	feed(tb, "\x1b[2*x\x1b[1;2;2;3;7$r")
+	assert.False(t, isOn(tb.viewport[1].Columns[0].Styles.Reverse))
+	assert.True(t, isOn(tb.viewport[1].Columns[1].Styles.Reverse))
+
+	// DECRARA toggles and DECCARA 0 turns everything off
+	feed(tb, "\x1b[1;1;1;4;7$t")
+	assert.True(t, isOn(tb.viewport[0].Columns[0].Styles.Reverse))
+	assert.False(t, isOn(tb.viewport[0].Columns[1].Styles.Reverse))
+	feed(tb, "\x1b[1;1;3;4$r")
+	assert.False(t, isOn(tb.viewport[0].Columns[0].Styles.Reverse))
+
+	// 22 turns bold off; 21 is not an attribute of DECCARA
+	feed(tb, "\x1b[1;1;1;4;1;4$r\x1b[1;1;1;2;22$r\x1b[1;1;1;4;21$r")
+	assert.False(t, isOn(tb.viewport[0].Columns[0].Styles.Bold))
+	assert.True(t, isOn(tb.viewport[0].Columns[0].Styles.Underline))
+	assert.True(t, isOn(tb.viewport[0].Columns[2].Styles.Bold))
+	assert.True(t, isOn(tb.viewport[0].Columns[3].Styles.Bold))
+}
+
+func TestTerminalBufferLineSize(t *testing.T) {
+	tb := NewTerminalBuffer(10, 3)
+	feed(tb, "0123456789\r\n\x1b#6Wide\x1b[2;9H")
+
+	// The cursor is limited to the left half of a double-width row
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 4, x)
+	assert.Equal(t, 1, y)
+	assert.Equal(t, LineSizeDoubleWidth, tb.viewport[1].LineSize)
+
+	// Printing wraps at the middle of the row
+	feed(tb, "\x1b[2;5Hxyz")
+	assert.Equal(t, "0123456789\nW i d e x \nyz", tb.GetDisplay())
+
+	// Making a row double width drops its right half
+	feed(tb, "\x1b[1;1H\x1b#3")
+	assert.Equal(t, "0 1 2 3 4", strings.TrimRight(tb.viewport[0].displayString(), " "))
+	assert.Equal(t, "01234", rowText(tb, 0))
+	assert.Contains(t, tb.GetDisplayWithColors(), "\x1b#301234")
+
+	// DECSWL restores single width
+	feed(tb, "\x1b#5\x1b[1;10H")
+	x, _ = tb.CursorPosition()
+	assert.Equal(t, 9, x)
+
+	// EL keeps the line size; ED resets the rows it erases completely
+	feed(tb, "\x1b[2;1H\x1b[2K\x1b[K")
+	assert.Equal(t, LineSizeDoubleWidth, tb.viewport[1].LineSize)
+	feed(tb, "\x1b[3;1H\x1b#6\x1b[2;3H\x1b[J")
+	assert.Equal(t, LineSizeDoubleWidth, tb.viewport[1].LineSize)
+	assert.Equal(t, LineSizeSingle, tb.viewport[2].LineSize)
+	feed(tb, "\x1b[2J")
+	assert.Equal(t, LineSizeSingle, tb.viewport[1].LineSize)
+}
+
+func TestTerminalBufferScreenAlignment(t *testing.T) {
+	tb := NewTerminalBuffer(4, 3)
+	feed(tb, "\x1b[2;3r\x1b[2;2H\x1b#6\x1b#8")
+	assert.Equal(t, "EEEE\nEEEE\nEEEE", screenText(tb))
+	assert.Equal(t, LineSizeSingle, tb.viewport[1].LineSize)
+	assert.Nil(t, tb.scrollRegion)
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+}
+
+func TestTerminalBufferColumnMode(t *testing.T) {
+	tb := NewTerminalBuffer(80, 24)
+	var resizes [][2]int
+	tb.SetResizeHandler(func(width, height int) {
+		resizes = append(resizes, [2]int{width, height})
+	})
+
+	// DECCOLM is ignored unless mode 40 allows it
+	feed(tb, "hello\x1b[?3h")
+	width, _ := tb.Dimensions()
+	assert.Equal(t, 80, width)
+	assert.Empty(t, resizes)
+
+	// Switching clears the screen, resets the margins and homes the cursor
+	feed(tb, "\x1b[?40h\x1b[5;10r\x1b[3;3H\x1b[?3h")
+	width, height := tb.Dimensions()
+	assert.Equal(t, 132, width)
+	assert.Equal(t, 24, height)
+	assert.Equal(t, "", tb.GetDisplay())
+	assert.Nil(t, tb.scrollRegion)
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 0, x)
+	assert.Equal(t, 0, y)
+
+	feed(tb, "\x1b[?3l")
+	width, _ = tb.Dimensions()
+	assert.Equal(t, 80, width)
+	assert.Equal(t, [][2]int{{132, 24}, {80, 24}}, resizes)
+}
+
+func TestTerminalBufferPageSize(t *testing.T) {
+	tb := NewTerminalBuffer(80, 24)
+	var resizes [][2]int
+	tb.SetResizeHandler(func(width, height int) {
+		resizes = append(resizes, [2]int{width, height})
+	})
+
+	// DECSLPP must be allowed
+	feed(tb, "\x1b[9999t")
+	_, height := tb.Dimensions()
+	assert.Equal(t, 24, height)
+	assert.Empty(t, resizes)
+
+	// DECSCPP and DECSLPP keep the screen content
+	tb.SetPageResizeAllowed(true)
+	feed(tb, "hello\x1b[132$|\x1b[36t")
+	width, height := tb.Dimensions()
+	assert.Equal(t, 132, width)
+	assert.Equal(t, 36, height)
+	assert.Equal(t, "hello", tb.GetDisplay())
+
+	// The number of lines is limited
+	feed(tb, "\x1b[9999t")
+	_, height = tb.Dimensions()
+	assert.Equal(t, maxPageLines, height)
+	feed(tb, "\x1b[36t")
+
+	// Fewer than 24 lines is a window operation, not DECSLPP
+	feed(tb, "\x1b[8t\x1b[$|")
+	width, height = tb.Dimensions()
+	assert.Equal(t, 80, width)
+	assert.Equal(t, 36, height)
+	assert.Equal(t, [][2]int{{132, 24}, {132, 36}, {132, maxPageLines}, {132, 36}, {80, 36}}, resizes)
+}
+
+func TestTerminalBufferInsertMode(t *testing.T) {
+	tb := NewTerminalBuffer(8, 2)
+	feed(tb, "abcdefgh\x1b[1;3H\x1b[4hXY")
+	assert.Equal(t, "abXYcdef", rowText(tb, 0))
+
+	// Wide characters shift the line by two columns, and a wide character
+	// pushed halfway past the edge is dropped
+	feed(tb, "\x1b[2;1H\x1b[4lab中cd\x1b[2;2H\x1b[4h一")
+	assert.Equal(t, "a一b中cd", rowText(tb, 1))
+	feed(tb, "\x1b[2;1H\x1b[4labcdef中\x1b[2;2H\x1b[4hX")
+	assert.Equal(t, "aXbcdef", rowText(tb, 1))
+
+	// The rest of the line stops at the right margin
+	feed(tb, "\x1b[4l\x1b[1;1Habcdefgh\x1b[?69h\x1b[2;5s\x1b[1;3H\x1b[4hZ")
+	assert.Equal(t, "abZcdfgh", rowText(tb, 0))
+
+	// Replace mode overwrites again
+	feed(tb, "\x1b[4lQ")
+	assert.Equal(t, "abZQdfgh", rowText(tb, 0))
+}
+
+func TestTerminalBufferNewlineMode(t *testing.T) {
+	tb := NewTerminalBuffer(10, 4)
+	feed(tb, "ab\ncd")
+	assert.Equal(t, "ab\n  cd\n\n", screenText(tb))
+
+	// LF, VT and FF return to the first column
+	feed(tb, "\x1b[20h\nef\x0bgh\x0cij")
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 2, x)
+	assert.Equal(t, 3, y)
+	assert.Equal(t, "  cd\nef\ngh\nij", screenText(tb))
+
+	// With margins the cursor returns to the left margin
+	tb = NewTerminalBuffer(10, 3)
+	feed(tb, "\x1b[20h\x1b[?69h\x1b[3;8s\x1b[1;5Hab\ncd")
+	assert.Equal(t, "  cd", rowText(tb, 1))
+}
+
+func TestTerminalBufferReverseVideo(t *testing.T) {
+	tb := NewTerminalBuffer(4, 1)
+	feed(tb, "ab\x1b[7mc")
+	normal := tb.GetDisplayWithColors()
+	assert.Equal(t, "ab\x1b[7mc\x1b[0m", normal)
+
+	// DECSCNM swaps the colors at render time without changing the cells
+	feed(tb, "\x1b[?5h")
+	assert.True(t, tb.IsReverseVideo())
+	assert.Equal(t, "\x1b[7mab\x1b[0mc\x1b[7m \x1b[0m", tb.GetDisplayWithColors())
+	assert.Equal(t, "abc", tb.GetDisplay())
+	assert.Nil(t, tb.viewport[0].Columns[0].Styles.Reverse)
+
+	feed(tb, "\x1b[?5l")
+	assert.Equal(t, normal, tb.GetDisplayWithColors())
+}
+
+func TestTerminalBufferBell(t *testing.T) {
+	tb := NewTerminalBuffer(10, 2)
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tb.now = func() time.Time { return clock }
+
+	var bells []BellEvent
+	tb.SetBellHandler(func(event BellEvent) {
+		bells = append(bells, event)
+	})
+
+	feed(tb, "\x07")
+	assert.Equal(t, []BellEvent{{Kind: BellAudible, Time: clock}}, bells)
+
+	// A short reverse video flash is a visual bell
+	feed(tb, "\x1b[?5h")
+	clock = clock.Add(100 * time.Millisecond)
+	feed(tb, "\x1b[?5l")
+	assert.Len(t, bells, 2)
+	assert.Equal(t, BellEvent{Kind: BellVisual, Time: clock}, bells[1])
+
+	// Staying in reverse video is not
+	feed(tb, "\x1b[?5h")
+	clock = clock.Add(time.Minute)
+	feed(tb, "\x1b[?5l")
+	assert.Len(t, bells, 2)
+
+	// OSC sequences terminated by BEL do not ring the bell
+	feed(tb, "\x1b]2;title\x07")
+	assert.Len(t, bells, 2)
+}
+
+func TestTerminalBufferTitles(t *testing.T) {
+	tb := NewTerminalBuffer(10, 2)
+
+	feed(tb, "\x1b]0;shell\x07")
+	assert.Equal(t, "shell", tb.Title())
+	assert.Equal(t, "shell", tb.IconName())
+
+	feed(tb, "\x1b]1;icon\x07\x1b]2;window\x1b\\")
+	assert.Equal(t, "window", tb.Title())
+	assert.Equal(t, "icon", tb.IconName())
+
+	// Push both, then the title only
+	feed(tb, "\x1b[22;0t\x1b]0;vim\x07\x1b[22;2t\x1b]2;vim - file\x07")
+	assert.Equal(t, "vim - file", tb.Title())
+
+	feed(tb, "\x1b[23;2t")
+	assert.Equal(t, "vim", tb.Title())
+	assert.Equal(t, "vim", tb.IconName())
+
+	feed(tb, "\x1b[23t")
+	assert.Equal(t, "window", tb.Title())
+	assert.Equal(t, "icon", tb.IconName())
+
+	// Popping an empty stack keeps the titles
+	feed(tb, "\x1b[23t")
+	assert.Equal(t, "window", tb.Title())
+
+	feed(tb, "\x1bc")
+	assert.Empty(t, tb.Title())
+	assert.Empty(t, tb.IconName())
+}
+
+func TestTerminalBufferTitleStackLimit(t *testing.T) {
+	tb := NewTerminalBuffer(10, 2)
+
+	for i := 0; i < maxTitleStackDepth+2; i++ {
+		feed(tb, fmt.Sprintf("\x1b]2;title %d\x07\x1b[22;2t", i))
+	}
+	assert.Len(t, tb.titleStack, maxTitleStackDepth)
+
+	for i := 0; i < maxTitleStackDepth+2; i++ {
+		feed(tb, "\x1b[23;2t")
+	}
+	assert.Equal(t, "title 2", tb.Title())
+}
+
+func TestTerminalBufferTitleReports(t *testing.T) {
+	tb := NewTerminalBuffer(10, 2)
+	var responses strings.Builder
+	tb.SetResponseWriter(&responses)
+
+	feed(tb, "\x1b]1;icon\x07\x1b]2;window\x07\x1b[20t\x1b[21t")
+	assert.Empty(t, responses.String())
+
+	tb.SetTitleReporting(true)
+	feed(tb, "\x1b[20t\x1b[21t")
+	assert.Equal(t, "\x1b]Licon\x1b\\\x1b]lwindow\x1b\\", responses.String())
+
+	// Report requests do not change the page size
+	assert.Equal(t, 2, tb.height)
+}
+
+func TestTerminalBufferSaveRestoreModes(t *testing.T) {
+	tb := NewTerminalBuffer(10, 5)
+
+	// XTRESTORE is not DECSTBM
+	feed(tb, "\x1b[3;5H\x1b[?1r\x1b[?6r")
+	x, y := tb.CursorPosition()
+	assert.Equal(t, 4, x)
+	assert.Equal(t, 2, y)
+	assert.Nil(t, tb.scrollRegion)
+
+	// XTSAVE is not SCOSC
+	feed(tb, "\x1b7\x1b[1;1H\x1b[?7s\x1b8")
+	x, y = tb.CursorPosition()
+	assert.Equal(t, 4, x)
+	assert.Equal(t, 2, y)
+
+	// Saved modes are restored
+	feed(tb, "\x1b[?25l\x1b[?7l\x1b[?5r\x1b[?25;7r")
+	assert.True(t, tb.autowrap)
+	assert.True(t, tb.cursor.IsHidden)
+	assert.False(t, tb.reverseVideo)
+
+	feed(tb, "\x1b[?25s\x1b[?25h\x1b[?25r")
+	assert.True(t, tb.cursor.IsHidden)
+}
@@ -8,11 +8,16 @@ import (
 	"strings"
 )
 
-// TerminalCharacter represents a single terminal character with its styling
+// TerminalCharacter represents a single terminal character with its styling.
+// A wide character has Width 2 and is followed by a spacer cell.
 type TerminalCharacter struct {
 	Character rune
+	Combining string // Marks and joined runes that follow Character in its grapheme cluster
 	Width     int
 	Styles    CharacterStyles
+
+	// wideSpacer marks the second column of a wide character
+	wideSpacer bool
 }
 
 // NewTerminalCharacter creates a new terminal character with default styles
@@ -44,20 +49,63 @@ func EmptyTerminalCharacter() TerminalCharacter {
 	}
 }
 
+// wideCharSpacer returns the placeholder for the second column of a wide character
+func wideCharSpacer(styles CharacterStyles) TerminalCharacter {
+	return TerminalCharacter{
+		Character:  ' ',
+		Width:      0,
+		Styles:     styles,
+		wideSpacer: true,
+	}
+}
+
+// IsWideCharSpacer checks if this cell is the second column of a wide character
+func (tc *TerminalCharacter) IsWideCharSpacer() bool {
+	return tc.wideSpacer
+}
+
+// Grapheme returns the full grapheme cluster stored in this cell
+func (tc *TerminalCharacter) Grapheme() string {
+	return string(tc.Character) + tc.Combining
+}
+
 // CharacterStyles holds character styling attributes
 type CharacterStyles struct {
-	Foreground *AnsiCode
-	Background *AnsiCode
-	Bold       *AnsiCode
-	Dim        *AnsiCode
-	Italic     *AnsiCode
-	Underline  *AnsiCode
-	Blink      *AnsiCode
-	Reverse    *AnsiCode
-	Hidden     *AnsiCode
-	Strike     *AnsiCode
+	Foreground     *AnsiCode
+	Background     *AnsiCode
+	UnderlineColor *AnsiCode
+	Bold           *AnsiCode
+	Dim            *AnsiCode
+	Italic         *AnsiCode
+	Underline      *AnsiCode
+	UnderlineStyle UnderlineStyle
+	Blink          *AnsiCode
+	Reverse        *AnsiCode
+	Hidden         *AnsiCode
+	Strike         *AnsiCode
+	Overline       *AnsiCode
+	Fraktur        *AnsiCode
+	Superscript    *AnsiCode
+	Subscript      *AnsiCode
+	Font           int // 0 is the primary font, 1-9 select alternate fonts
+
+	// Protected characters survive selective erase (DECSCA, SPA). It does not
+	// affect rendering, so it is ignored when comparing styles, and SGR 0
+	// leaves it unchanged.
+	Protected bool
 }
 
+// UnderlineStyle represents the style of an underline (SGR 4:x)
+type UnderlineStyle int
+
+const (
+	UnderlineStyleSingle UnderlineStyle = iota
+	UnderlineStyleDouble
+	UnderlineStyleCurly
+	UnderlineStyleDotted
+	UnderlineStyleDashed
+)
+
 // DefaultCharacterStyles returns default character styles (all nil)
 func DefaultCharacterStyles() CharacterStyles {
 	return CharacterStyles{}
@@ -78,7 +126,11 @@ func (cs *CharacterStyles) ToAnsiSequence() string {
 		sequence.WriteString("\x1b[3m")
 	}
 	if cs.Underline != nil && cs.Underline.Type == AnsiCodeTypeOn {
-		sequence.WriteString("\x1b[4m")
+		if cs.UnderlineStyle == UnderlineStyleSingle {
+			sequence.WriteString("\x1b[4m")
+		} else {
+			fmt.Fprintf(&sequence, "\x1b[4:%dm", cs.UnderlineStyle+1)
+		}
 	}
 	if cs.Blink != nil && cs.Blink.Type == AnsiCodeTypeOn {
 		sequence.WriteString("\x1b[5m")
@@ -92,6 +144,21 @@ func (cs *CharacterStyles) ToAnsiSequence() string {
 	if cs.Strike != nil && cs.Strike.Type == AnsiCodeTypeOn {
 		sequence.WriteString("\x1b[9m")
 	}
+	if cs.Font > 0 {
+		fmt.Fprintf(&sequence, "\x1b[%dm", 10+cs.Font)
+	}
+	if cs.Fraktur != nil && cs.Fraktur.Type == AnsiCodeTypeOn {
+		sequence.WriteString("\x1b[20m")
+	}
+	if cs.Overline != nil && cs.Overline.Type == AnsiCodeTypeOn {
+		sequence.WriteString("\x1b[53m")
+	}
+	if cs.Superscript != nil && cs.Superscript.Type == AnsiCodeTypeOn {
+		sequence.WriteString("\x1b[73m")
+	}
+	if cs.Subscript != nil && cs.Subscript.Type == AnsiCodeTypeOn {
+		sequence.WriteString("\x1b[74m")
+	}
 
 	// Handle colors
 	if cs.Foreground != nil {
@@ -100,6 +167,9 @@ func (cs *CharacterStyles) ToAnsiSequence() string {
 	if cs.Background != nil {
 		sequence.WriteString(cs.Background.ToAnsiBgSequence())
 	}
+	if cs.UnderlineColor != nil {
+		sequence.WriteString(cs.UnderlineColor.ToAnsiUnderlineSequence())
+	}
 
 	return sequence.String()
 }
@@ -113,14 +183,21 @@ func (cs *CharacterStyles) DiffersFrom(other *CharacterStyles) bool {
 func (cs *CharacterStyles) equals(other *CharacterStyles) bool {
 	return ansiCodeEquals(cs.Foreground, other.Foreground) &&
 		ansiCodeEquals(cs.Background, other.Background) &&
+		ansiCodeEquals(cs.UnderlineColor, other.UnderlineColor) &&
 		ansiCodeEquals(cs.Bold, other.Bold) &&
 		ansiCodeEquals(cs.Dim, other.Dim) &&
 		ansiCodeEquals(cs.Italic, other.Italic) &&
 		ansiCodeEquals(cs.Underline, other.Underline) &&
+		cs.UnderlineStyle == other.UnderlineStyle &&
 		ansiCodeEquals(cs.Blink, other.Blink) &&
 		ansiCodeEquals(cs.Reverse, other.Reverse) &&
 		ansiCodeEquals(cs.Hidden, other.Hidden) &&
-		ansiCodeEquals(cs.Strike, other.Strike)
+		ansiCodeEquals(cs.Strike, other.Strike) &&
+		ansiCodeEquals(cs.Overline, other.Overline) &&
+		ansiCodeEquals(cs.Fraktur, other.Fraktur) &&
+		ansiCodeEquals(cs.Superscript, other.Superscript) &&
+		ansiCodeEquals(cs.Subscript, other.Subscript) &&
+		cs.Font == other.Font
 }
 
 // AddStyleFromAnsiParams applies SGR (Select Graphic Rendition) parameters
@@ -135,7 +212,9 @@ func (cs *CharacterStyles) AddStyleFromAnsiParams(params [][]uint16) {
 
 		switch param {
 		case 0: // Reset
+			protected := cs.Protected
 			*cs = DefaultCharacterStyles()
+			cs.Protected = protected
 		case 1: // Bold
 			bold := AnsiCodeOn()
 			cs.Bold = &bold
@@ -145,9 +224,21 @@ func (cs *CharacterStyles) AddStyleFromAnsiParams(params [][]uint16) {
 		case 3: // Italic
 			italic := AnsiCodeOn()
 			cs.Italic = &italic
-		case 4: // Underline
-			underline := AnsiCodeOn()
-			cs.Underline = &underline
+		case 4: // Underline, with an optional style subparameter
+			style := uint16(1)
+			if len(params[i]) > 1 {
+				style = params[i][1]
+			}
+			switch {
+			case style == 0:
+				reset := AnsiCodeReset()
+				cs.Underline = &reset
+				cs.UnderlineStyle = UnderlineStyleSingle
+			case style <= 5:
+				underline := AnsiCodeOn()
+				cs.Underline = &underline
+				cs.UnderlineStyle = UnderlineStyle(style - 1)
+			}
 		case 5, 6: // Blink
 			blink := AnsiCodeOn()
 			cs.Blink = &blink
@@ -160,20 +251,28 @@ func (cs *CharacterStyles) AddStyleFromAnsiParams(params [][]uint16) {
 		case 9: // Strike
 			strike := AnsiCodeOn()
 			cs.Strike = &strike
+		case 10, 11, 12, 13, 14, 15, 16, 17, 18, 19: // Primary and alternate fonts
+			cs.Font = int(param) - 10
+		case 20: // Fraktur
+			fraktur := AnsiCodeOn()
+			cs.Fraktur = &fraktur
+		case 21: // Double underline
+			underline := AnsiCodeOn()
+			cs.Underline = &underline
+			cs.UnderlineStyle = UnderlineStyleDouble
 		// Reset individual attributes
-		case 21: // Bold off
-			reset := AnsiCodeReset()
-			cs.Bold = &reset
 		case 22: // Bold and dim off
 			reset := AnsiCodeReset()
 			cs.Bold = &reset
 			cs.Dim = &reset
-		case 23: // Italic off
+		case 23: // Italic and fraktur off
 			reset := AnsiCodeReset()
 			cs.Italic = &reset
+			cs.Fraktur = &reset
 		case 24: // Underline off
 			reset := AnsiCodeReset()
 			cs.Underline = &reset
+			cs.UnderlineStyle = UnderlineStyleSingle
 		case 25: // Blink off
 			reset := AnsiCodeReset()
 			cs.Blink = &reset
@@ -191,7 +290,10 @@ func (cs *CharacterStyles) AddStyleFromAnsiParams(params [][]uint16) {
 			color := AnsiCodeNamedColor(NamedColorFromAnsi(uint8(param)))
 			cs.Foreground = &color
 		case 38: // Extended foreground color
-			consumed := cs.handleExtendedColor(params[i:], true)
+			color, consumed, ok := parseExtendedColor(params[i:])
+			if ok {
+				cs.Foreground = &color
+			}
 			i += consumed - 1 // -1 because loop will increment
 		case 39: // Default foreground
 			reset := AnsiCodeReset()
@@ -201,11 +303,41 @@ func (cs *CharacterStyles) AddStyleFromAnsiParams(params [][]uint16) {
 			color := AnsiCodeNamedColor(NamedColorFromAnsi(uint8(param - 10)))
 			cs.Background = &color
 		case 48: // Extended background color
-			consumed := cs.handleExtendedColor(params[i:], false)
+			color, consumed, ok := parseExtendedColor(params[i:])
+			if ok {
+				cs.Background = &color
+			}
 			i += consumed - 1 // -1 because loop will increment
 		case 49: // Default background
 			reset := AnsiCodeReset()
 			cs.Background = &reset
+		case 53: // Overline
+			overline := AnsiCodeOn()
+			cs.Overline = &overline
+		case 55: // Overline off
+			reset := AnsiCodeReset()
+			cs.Overline = &reset
+		case 58: // Underline color
+			color, consumed, ok := parseExtendedColor(params[i:])
+			if ok {
+				cs.UnderlineColor = &color
+			}
+			i += consumed - 1 // -1 because loop will increment
+		case 59: // Default underline color
+			reset := AnsiCodeReset()
+			cs.UnderlineColor = &reset
+		case 73: // Superscript
+			on, reset := AnsiCodeOn(), AnsiCodeReset()
+			cs.Superscript = &on
+			cs.Subscript = &reset
+		case 74: // Subscript
+			on, reset := AnsiCodeOn(), AnsiCodeReset()
+			cs.Subscript = &on
+			cs.Superscript = &reset
+		case 75: // Superscript and subscript off
+			reset := AnsiCodeReset()
+			cs.Superscript = &reset
+			cs.Subscript = &reset
 		// Bright foreground colors
 		case 90, 91, 92, 93, 94, 95, 96, 97:
 			color := AnsiCodeNamedColor(NamedColorFromAnsi(uint8(param - 60)))
@@ -219,49 +351,54 @@ func (cs *CharacterStyles) AddStyleFromAnsiParams(params [][]uint16) {
 	}
 }
 
-// handleExtendedColor processes 38/48 (extended color) sequences
-func (cs *CharacterStyles) handleExtendedColor(params [][]uint16, isForeground bool) int {
+// parseExtendedColor processes 38/48/58 (extended color) sequences.
+// It accepts the colon form (38:2:r:g:b, 38:2:cs:r:g:b, 38:2::r:g:b, 38:5:n)
+// and the semicolon form (38;2;r;g;b, 38;5;n), and returns the number of
+// parameter groups consumed.
+func parseExtendedColor(params [][]uint16) (AnsiCode, int, bool) {
+	first := params[0]
+
+	// Colon form: everything is in a single parameter group
+	if len(first) > 1 {
+		switch first[1] {
+		case 2: // RGB color
+			switch {
+			case len(first) >= 6: // With (possibly empty) colorspace id
+				return rgbAnsiCode(first[3], first[4], first[5]), 1, true
+			case len(first) == 5:
+				return rgbAnsiCode(first[2], first[3], first[4]), 1, true
+			}
+		case 5: // 256 color
+			if len(first) >= 3 {
+				return AnsiCodeColorIndex(clampUint8(first[2])), 1, true
+			}
+		}
+		return AnsiCode{}, 1, false
+	}
+
+	// Semicolon form: the color spans the following parameter groups
 	if len(params) < 2 || len(params[1]) == 0 {
-		return 1
+		return AnsiCode{}, 1, false
 	}
 
-	colorType := params[1][0]
-	switch colorType {
+	switch params[1][0] {
 	case 2: // RGB color
-		if len(params) < 5 {
-			return 1
+		if len(params) < 5 || len(params[2]) == 0 || len(params[3]) == 0 || len(params[4]) == 0 {
+			return AnsiCode{}, len(params), false
 		}
-		var r, g, b uint8 = 0, 0, 0
-		if len(params[2]) > 0 {
-			r = uint8(params[2][0])
-		}
-		if len(params[3]) > 0 {
-			g = uint8(params[3][0])
-		}
-		if len(params[4]) > 0 {
-			b = uint8(params[4][0])
-		}
-		color := AnsiCodeRgbCode(r, g, b)
-		if isForeground {
-			cs.Foreground = &color
-		} else {
-			cs.Background = &color
-		}
-		return 5
+		return rgbAnsiCode(params[2][0], params[3][0], params[4][0]), 5, true
 	case 5: // 256 color
 		if len(params) < 3 || len(params[2]) == 0 {
-			return 2
-		}
-		index := uint8(params[2][0])
-		color := AnsiCodeColorIndex(index)
-		if isForeground {
-			cs.Foreground = &color
-		} else {
-			cs.Background = &color
+			return AnsiCode{}, len(params), false
 		}
-		return 3
+		return AnsiCodeColorIndex(clampUint8(params[2][0])), 3, true
 	}
-	return 1
+	return AnsiCode{}, 2, false
+}
+
+// rgbAnsiCode creates an RGB AnsiCode from parameter values
+func rgbAnsiCode(r, g, b uint16) AnsiCode {
+	return AnsiCodeRgbCode(clampUint8(r), clampUint8(g), clampUint8(b))
 }
 
 // AnsiCodeType represents the type of ANSI color code
@@ -353,6 +490,26 @@ func (ac AnsiCode) ToAnsiBgSequence() string {
 	}
 }
 
+// ToAnsiUnderlineSequence converts to ANSI underline color sequence.
+// The colon form is used so that terminals without underline color
+// support skip the whole parameter instead of misreading its values.
+func (ac AnsiCode) ToAnsiUnderlineSequence() string {
+	switch ac.Type {
+	case AnsiCodeTypeOn:
+		return ""
+	case AnsiCodeTypeReset:
+		return "\x1b[59m"
+	case AnsiCodeTypeNamedColor:
+		return fmt.Sprintf("\x1b[58:5:%dm", ac.NamedColor)
+	case AnsiCodeTypeRgb:
+		return fmt.Sprintf("\x1b[58:2::%d:%d:%dm", ac.RGB.R, ac.RGB.G, ac.RGB.B)
+	case AnsiCodeTypeColorIndex:
+		return fmt.Sprintf("\x1b[58:5:%dm", ac.ColorIndex)
+	default:
+		return ""
+	}
+}
+
 // NamedColor represents named ANSI colors
 type NamedColor int
 
@@ -463,16 +620,12 @@ func (nc NamedColor) ToAnsiBg() uint8 {
 
 // Helper functions
 
-// runeWidth calculates the display width of a rune
-func runeWidth(r rune) int {
-	if r < 32 || r == 127 {
-		return 0 // Control characters
-	}
-	if r < 127 {
-		return 1 // ASCII
+// clampUint8 clamps a parameter value to the uint8 range
+func clampUint8(value uint16) uint8 {
+	if value > 255 {
+		return 255
 	}
-	// Simplified width calculation - would use proper Unicode width library in production
-	return 1
+	return uint8(value)
 }
 
 // ansiCodeEquals compares two AnsiCode pointers for equality
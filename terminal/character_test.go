@@ -0,0 +1,138 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCharacterStylesExtendedSGR(t *testing.T) {
+	var cs CharacterStyles
+
+	cs.AddStyleFromAnsiParams([][]uint16{{4, 3}, {53}, {20}, {13}, {73}})
+	assert.Equal(t, AnsiCodeTypeOn, cs.Underline.Type)
+	assert.Equal(t, UnderlineStyleCurly, cs.UnderlineStyle)
+	assert.Equal(t, AnsiCodeTypeOn, cs.Overline.Type)
+	assert.Equal(t, AnsiCodeTypeOn, cs.Fraktur.Type)
+	assert.Equal(t, 3, cs.Font)
+	assert.Equal(t, AnsiCodeTypeOn, cs.Superscript.Type)
+
+	cs.AddStyleFromAnsiParams([][]uint16{{74}})
+	assert.Equal(t, AnsiCodeTypeOn, cs.Subscript.Type)
+	assert.Equal(t, AnsiCodeTypeReset, cs.Superscript.Type)
+
+	cs.AddStyleFromAnsiParams([][]uint16{{4, 0}, {55}, {23}, {10}, {75}})
+	assert.Equal(t, AnsiCodeTypeReset, cs.Underline.Type)
+	assert.Equal(t, AnsiCodeTypeReset, cs.Overline.Type)
+	assert.Equal(t, AnsiCodeTypeReset, cs.Fraktur.Type)
+	assert.Equal(t, 0, cs.Font)
+	assert.Equal(t, AnsiCodeTypeReset, cs.Subscript.Type)
+
+	cs.AddStyleFromAnsiParams([][]uint16{{21}})
+	assert.Equal(t, AnsiCodeTypeOn, cs.Underline.Type)
+	assert.Equal(t, UnderlineStyleDouble, cs.UnderlineStyle)
+	assert.Nil(t, cs.Bold, "SGR 21 is double underline, not bold off")
+}
+
+func TestCharacterStylesExtendedColors(t *testing.T) {
+	tests := []struct {
+		name   string
+		params [][]uint16
+		check  func(*testing.T, CharacterStyles)
+	}{
+		{
+			"semicolon RGB foreground",
+			[][]uint16{{38}, {2}, {1}, {2}, {3}},
+			func(t *testing.T, cs CharacterStyles) {
+				assert.True(t, cs.Foreground.equals(AnsiCodeRgbCode(1, 2, 3)))
+			},
+		},
+		{
+			"colon RGB foreground",
+			[][]uint16{{38, 2, 1, 2, 3}},
+			func(t *testing.T, cs CharacterStyles) {
+				assert.True(t, cs.Foreground.equals(AnsiCodeRgbCode(1, 2, 3)))
+			},
+		},
+		{
+			"colon RGB background with colorspace",
+			[][]uint16{{48, 2, 0, 1, 2, 3}},
+			func(t *testing.T, cs CharacterStyles) {
+				assert.True(t, cs.Background.equals(AnsiCodeRgbCode(1, 2, 3)))
+			},
+		},
+		{
+			"colon indexed underline color",
+			[][]uint16{{58, 5, 196}},
+			func(t *testing.T, cs CharacterStyles) {
+				assert.True(t, cs.UnderlineColor.equals(AnsiCodeColorIndex(196)))
+			},
+		},
+		{
+			"semicolon underline color followed by bold",
+			[][]uint16{{58}, {2}, {9}, {8}, {7}, {1}},
+			func(t *testing.T, cs CharacterStyles) {
+				assert.True(t, cs.UnderlineColor.equals(AnsiCodeRgbCode(9, 8, 7)))
+				assert.Equal(t, AnsiCodeTypeOn, cs.Bold.Type)
+			},
+		},
+		{
+			"underline color reset",
+			[][]uint16{{58, 5, 1}, {59}},
+			func(t *testing.T, cs CharacterStyles) {
+				assert.Equal(t, AnsiCodeTypeReset, cs.UnderlineColor.Type)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cs CharacterStyles
+			cs.AddStyleFromAnsiParams(tt.params)
+			tt.check(t, cs)
+		})
+	}
+}
+
+func TestCharacterStylesRoundTrip(t *testing.T) {
+	inputs := []string{
+		"\x1b[4:3m\x1b[58:2::255:0:128mX",
+		"\x1b[4:2;53;20;73mX",
+		"\x1b[4:5;14;74;58;5;33mX",
+		"\x1b[1;3;38:2:1:10:20:30;48;5;17mX",
+	}
+
+	for _, input := range inputs {
+		first := CreateTerminalFromString(input, 10, 1)
+		styles := first.viewport[0].Columns[0].Styles
+
+		second := CreateTerminalFromString(styles.ToAnsiSequence()+"X", 10, 1)
+		roundTripped := second.viewport[0].Columns[0].Styles
+
+		assert.True(t, styles.equals(&roundTripped), "round trip of %q gave %q", input, styles.ToAnsiSequence())
+	}
+}
+
+func TestTerminalCharacterWideCharSpacer(t *testing.T) {
+	// Zero-width characters are not spacers
+	mark := NewTerminalCharacter('\u0301')
+	assert.Equal(t, 0, mark.Width)
+	assert.False(t, mark.IsWideCharSpacer())
+
+	row := NewRowWithWidth(4)
+	row.Set(0, NewTerminalCharacter('a'))
+	row.Set(1, mark)
+	assert.Equal(t, "a\u0301  ", row.ToString())
+
+	// Wide characters from the constructors get a spacer cell
+	row.Set(2, NewTerminalCharacter('中'))
+	assert.True(t, row.Columns[3].IsWideCharSpacer())
+	assert.Equal(t, "a\u0301中", row.ToString())
+
+	pushed := NewRow()
+	pushed.Push(NewStyledTerminalCharacter('文', DefaultCharacterStyles()))
+	pushed.Push(NewTerminalCharacter('x'))
+	assert.Len(t, pushed.Columns, 3)
+	assert.Equal(t, "文x", pushed.ToString())
+	assert.Equal(t, 3, pushed.Width())
+}
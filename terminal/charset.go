@@ -0,0 +1,20 @@
+//! Terminal character sets
+//! Tracks the G0-G3 designations and which of them is invoked into GL
+
+package terminal
+
+import "github.com/cliofy/govte"
+
+// CharsetState holds the character set designations and the shift state.
+// The zero value designates ASCII everywhere with G0 invoked into GL.
+type CharsetState struct {
+	// Character sets designated as G0-G3
+	Designations [4]govte.StandardCharset
+	// Character set invoked into GL by SI, SO, LS2 and LS3
+	GL govte.CharsetIndex
+}
+
+// Map translates a printed character through the character set at index
+func (cs *CharsetState) Map(index govte.CharsetIndex, c rune) rune {
+	return cs.Designations[index].Map(c)
+}
@@ -10,6 +10,11 @@ type Cursor struct {
 	PendingStyles CharacterStyles
 	Shape         CursorShape
 	IsHidden      bool
+	IsBlinking    bool
+
+	// WrapPending is set when a character was printed in the last column;
+	// the next printed character wraps to a new line first
+	WrapPending bool
 }
 
 // NewCursor creates a new cursor at the origin
@@ -27,36 +32,43 @@ func NewCursor() Cursor {
 func (c *Cursor) Goto(x, y int) {
 	c.X = x
 	c.Y = y
+	c.WrapPending = false
 }
 
 // MoveUp moves cursor up by n lines
 func (c *Cursor) MoveUp(n int) {
 	c.Y = max(0, c.Y-n)
+	c.WrapPending = false
 }
 
 // MoveDown moves cursor down by n lines
 func (c *Cursor) MoveDown(n int) {
 	c.Y += n
+	c.WrapPending = false
 }
 
 // MoveLeft moves cursor left by n columns
 func (c *Cursor) MoveLeft(n int) {
 	c.X = max(0, c.X-n)
+	c.WrapPending = false
 }
 
 // MoveRight moves cursor right by n columns
 func (c *Cursor) MoveRight(n int) {
 	c.X += n
+	c.WrapPending = false
 }
 
 // CarriageReturn moves cursor to beginning of line
 func (c *Cursor) CarriageReturn() {
 	c.X = 0
+	c.WrapPending = false
 }
 
 // LineFeed moves cursor to next line
 func (c *Cursor) LineFeed() {
 	c.Y++
+	c.WrapPending = false
 }
 
 // NewLine moves cursor to next line and beginning of line
@@ -68,9 +80,10 @@ func (c *Cursor) NewLine() {
 // SavePosition saves current cursor position
 func (c *Cursor) SavePosition() SavedCursor {
 	return SavedCursor{
-		X:      c.X,
-		Y:      c.Y,
-		Styles: c.PendingStyles,
+		X:           c.X,
+		Y:           c.Y,
+		Styles:      c.PendingStyles,
+		WrapPending: c.WrapPending,
 	}
 }
 
@@ -79,6 +92,7 @@ func (c *Cursor) RestorePosition(saved SavedCursor) {
 	c.X = saved.X
 	c.Y = saved.Y
 	c.PendingStyles = saved.Styles
+	c.WrapPending = saved.WrapPending
 }
 
 // ChangeShape changes cursor shape
@@ -86,6 +100,11 @@ func (c *Cursor) ChangeShape(shape CursorShape) {
 	c.Shape = shape
 }
 
+// SetBlinking enables or disables cursor blinking
+func (c *Cursor) SetBlinking(blinking bool) {
+	c.IsBlinking = blinking
+}
+
 // Show shows cursor
 func (c *Cursor) Show() {
 	c.IsHidden = false
@@ -98,9 +117,14 @@ func (c *Cursor) Hide() {
 
 // SavedCursor represents saved cursor state
 type SavedCursor struct {
-	X      int
-	Y      int
-	Styles CharacterStyles
+	X           int
+	Y           int
+	Styles      CharacterStyles
+	WrapPending bool
+
+	// Terminal state saved along with the cursor by DECSC
+	Charsets   CharsetState
+	OriginMode bool
 }
 
 // CursorShape represents cursor shape
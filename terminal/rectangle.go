@@ -0,0 +1,266 @@
+//! Rectangular area operations
+//! VT420 fill, erase, copy and attribute changes on screen rectangles
+
+package terminal
+
+import "github.com/cliofy/govte"
+
+// rectangle is a screen area with 0-based inclusive bounds
+type rectangle struct {
+	top, left, bottom, right int
+}
+
+// rectangleDispatch handles the CSI sequences of the rectangular area
+// operations and DECSACE. It reports whether the sequence was one of them.
+func (tb *TerminalBuffer) rectangleDispatch(paramGroups [][]uint16, intermediates []byte, action rune) bool {
+	if len(intermediates) != 1 {
+		return false
+	}
+
+	switch {
+	case intermediates[0] == '$' && action == 'x': // DECFRA - Fill Rectangular Area
+		c := paramValue(paramGroups, 0)
+		if area, ok := tb.rectangleParams(paramGroups, 1); ok && isFillCharacter(c) {
+			tb.fillRectangle(area, rune(c)) //nolint:gosec // c is a validated character code
+		}
+
+	case intermediates[0] == '$' && action == 'z': // DECERA - Erase Rectangular Area
+		if area, ok := tb.rectangleParams(paramGroups, 0); ok {
+			tb.eraseRectangle(area, false)
+		}
+
+	case intermediates[0] == '$' && action == '{': // DECSERA - Selective Erase Rectangular Area
+		if area, ok := tb.rectangleParams(paramGroups, 0); ok {
+			tb.eraseRectangle(area, true)
+		}
+
+	case intermediates[0] == '$' && action == 'v': // DECCRA - Copy Rectangular Area
+		if area, ok := tb.rectangleParams(paramGroups, 0); ok {
+			top, left := tb.originPosition(countParam(paramGroups, 5)-1, countParam(paramGroups, 6)-1)
+			tb.copyRectangle(area, top, left)
+		}
+
+	case intermediates[0] == '$' && action == 'r': // DECCARA - Change Attributes in Rectangular Area
+		if area, ok := tb.rectangleParams(paramGroups, 0); ok {
+			attrs := attributeParams(paramGroups, 4)
+			tb.forEachAttributeCell(area, func(cell *TerminalCharacter) {
+				for _, attr := range attrs {
+					changeAttribute(&cell.Styles, attr)
+				}
+			})
+		}
+
+	case intermediates[0] == '$' && action == 't': // DECRARA - Reverse Attributes in Rectangular Area
+		if area, ok := tb.rectangleParams(paramGroups, 0); ok {
+			attrs := attributeParams(paramGroups, 4)
+			tb.forEachAttributeCell(area, func(cell *TerminalCharacter) {
+				for _, attr := range attrs {
+					reverseAttribute(&cell.Styles, attr)
+				}
+			})
+		}
+
+	case intermediates[0] == '*' && action == 'x': // DECSACE - Select Attribute Change Extent
+		if paramValue(paramGroups, 0) == 2 {
+			tb.attributeExtent = govte.AttributeChangeRectangle
+		} else {
+			tb.attributeExtent = govte.AttributeChangeStream
+		}
+
+	default:
+		return false
+	}
+
+	return true
+}
+
+// rectangleParams reads the top, left, bottom and right parameters starting at
+// start. In origin mode they are relative to the margins and the area is
+// clipped to them. It reports whether the area is not empty.
+func (tb *TerminalBuffer) rectangleParams(paramGroups [][]uint16, start int) (rectangle, bool) {
+	minY, maxY, minX, maxX := tb.addressableArea()
+
+	area := rectangle{
+		top:    minY + countParam(paramGroups, start) - 1,
+		left:   minX + countParam(paramGroups, start+1) - 1,
+		bottom: maxY,
+		right:  maxX,
+	}
+	if bottom := paramValue(paramGroups, start+2); bottom > 0 {
+		area.bottom = min(maxY, minY+bottom-1)
+	}
+	if right := paramValue(paramGroups, start+3); right > 0 {
+		area.right = min(maxX, minX+right-1)
+	}
+
+	return area, area.top <= area.bottom && area.left <= area.right
+}
+
+// addressableArea returns the rows and columns the cursor can be addressed
+// to: the margins in origin mode, otherwise the whole screen
+func (tb *TerminalBuffer) addressableArea() (int, int, int, int) {
+	if tb.originMode {
+		top, bottom := tb.scrollBounds()
+		left, right := tb.horizontalBounds()
+		return top, bottom, left, right
+	}
+	return 0, tb.height - 1, 0, tb.width - 1
+}
+
+// originPosition converts a 0-based position to screen coordinates, relative
+// to the margins in origin mode
+func (tb *TerminalBuffer) originPosition(y, x int) (int, int) {
+	minY, _, minX, _ := tb.addressableArea()
+	return minY + y, minX + x
+}
+
+// fillRectangle fills the area with c using the current styles
+func (tb *TerminalBuffer) fillRectangle(area rectangle, c rune) {
+	char := NewStyledTerminalCharacter(c, tb.currentStyles)
+	char.Width = 1
+	for y := area.top; y <= area.bottom; y++ {
+		tb.viewport[y].EnsureWidth(tb.width)
+		tb.viewport[y].ReplaceRange(area.left, area.right+1, char)
+	}
+}
+
+// eraseRectangle erases the area; a selective erase keeps protected characters
+func (tb *TerminalBuffer) eraseRectangle(area rectangle, selective bool) {
+	fill := tb.eraseCharacter()
+	for y := area.top; y <= area.bottom; y++ {
+		row := &tb.viewport[y]
+		row.EnsureWidth(tb.width)
+		if selective {
+			row.EraseUnprotected(area.left, area.right+1, fill)
+		} else {
+			row.ReplaceRange(area.left, area.right+1, fill)
+		}
+	}
+}
+
+// copyRectangle copies the area so that its top-left corner is at top, left.
+// The copy is clipped to the screen and may overlap the source.
+func (tb *TerminalBuffer) copyRectangle(area rectangle, top, left int) {
+	if top < 0 || top >= tb.height || left < 0 || left >= tb.width {
+		return
+	}
+
+	height := min(area.bottom-area.top+1, tb.height-top)
+	width := min(area.right-area.left+1, tb.width-left)
+
+	cells := make([][]TerminalCharacter, height)
+	for i := range cells {
+		row := &tb.viewport[area.top+i]
+		row.EnsureWidth(tb.width)
+		cells[i] = append([]TerminalCharacter(nil), row.Columns[area.left:area.left+width]...)
+	}
+
+	for i, columns := range cells {
+		row := &tb.viewport[top+i]
+		row.EnsureWidth(tb.width)
+		copy(row.Columns[left:left+width], columns)
+		row.repairWideChars()
+	}
+}
+
+// forEachAttributeCell calls fn for every cell changed by DECCARA or DECRARA.
+// With the stream extent the area runs from its top-left to its bottom-right
+// corner, wrapping at the line ends.
+func (tb *TerminalBuffer) forEachAttributeCell(area rectangle, fn func(cell *TerminalCharacter)) {
+	for y := area.top; y <= area.bottom; y++ {
+		left, right := area.left, area.right
+		if tb.attributeExtent == govte.AttributeChangeStream {
+			if y > area.top {
+				left = 0
+			}
+			if y < area.bottom {
+				right = tb.width - 1
+			}
+		}
+
+		row := &tb.viewport[y]
+		row.EnsureWidth(tb.width)
+		for x := left; x <= right; x++ {
+			fn(&row.Columns[x])
+		}
+	}
+}
+
+// attributeStyle returns the style field changed by an attribute of DECCARA
+// and DECRARA: 1 bold, 4 underline, 5 blink and 7 reverse
+func attributeStyle(styles *CharacterStyles, attr int) **AnsiCode {
+	switch attr {
+	case 1:
+		return &styles.Bold
+	case 4:
+		return &styles.Underline
+	case 5:
+		return &styles.Blink
+	case 7:
+		return &styles.Reverse
+	}
+	return nil
+}
+
+// attributesOff maps the DECCARA attributes that turn an attribute off to
+// that attribute
+var attributesOff = map[int]int{22: 1, 24: 4, 25: 5, 27: 7}
+
+// setAttribute turns a DECCARA and DECRARA attribute on or off
+func setAttribute(styles *CharacterStyles, attr int, enabled bool) {
+	field := attributeStyle(styles, attr)
+	if field == nil {
+		return
+	}
+	code := AnsiCodeReset()
+	if enabled {
+		code = AnsiCodeOn()
+	}
+	*field = &code
+}
+
+// changeAttribute applies a DECCARA attribute; 0 turns all of them off and
+// 22, 24, 25 and 27 turn one off. Other attributes are ignored.
+func changeAttribute(styles *CharacterStyles, attr int) {
+	if attr == 0 {
+		for _, a := range []int{1, 4, 5, 7} {
+			setAttribute(styles, a, false)
+		}
+	} else if off, ok := attributesOff[attr]; ok {
+		setAttribute(styles, off, false)
+	} else {
+		setAttribute(styles, attr, true)
+	}
+}
+
+// reverseAttribute toggles a DECRARA attribute; 0 toggles all of them
+func reverseAttribute(styles *CharacterStyles, attr int) {
+	if attr == 0 {
+		for _, a := range []int{1, 4, 5, 7} {
+			reverseAttribute(styles, a)
+		}
+		return
+	}
+
+	if field := attributeStyle(styles, attr); field != nil {
+		setAttribute(styles, attr, *field == nil || (*field).Type != AnsiCodeTypeOn)
+	}
+}
+
+// attributeParams reads the attributes of DECCARA and DECRARA starting at
+// start; no attributes means 0
+func attributeParams(paramGroups [][]uint16, start int) []int {
+	attrs := []int{}
+	for i := start; i < len(paramGroups); i++ {
+		attrs = append(attrs, paramValue(paramGroups, i))
+	}
+	if len(attrs) == 0 {
+		attrs = append(attrs, 0)
+	}
+	return attrs
+}
+
+// isFillCharacter checks that DECFRA can fill with the character code c
+func isFillCharacter(c int) bool {
+	return (c >= 0x20 && c <= 0x7E) || (c >= 0xA0 && c <= 0xFF)
+}
@@ -0,0 +1,192 @@
+//! Terminal reflow
+//! Rewraps soft-wrapped lines when the terminal width changes
+
+package terminal
+
+// reflowCursor tracks a cursor position while rows are rewrapped
+type reflowCursor struct {
+	X, Y        int
+	WrapPending bool
+}
+
+// reflowPrimary rewraps the scrollback history and the primary screen rows at
+// a new size and returns the new screen rows. The cursor, when given, keeps
+// pointing at the same character unless the rows below it do not fit on the
+// screen.
+func (tb *TerminalBuffer) reflowPrimary(screen []Row, cursor *reflowCursor, width, height int) []Row {
+	rows := make([]Row, 0, tb.scrollback.Len()+len(screen))
+	for i := 0; i < tb.scrollback.Len(); i++ {
+		rows = append(rows, *tb.scrollback.Get(i))
+	}
+	historyLen := len(rows)
+	rows = append(rows, screen...)
+
+	cursorRow := -1
+	if cursor != nil {
+		cursorRow = historyLen + cursor.Y
+	}
+
+	rewrapped, newCursorRow := rewrapRows(rows, cursorRow, cursor, width)
+
+	// Drop the blank rows below the content and the cursor
+	used := newCursorRow
+	for i := len(rewrapped) - 1; i > used; i-- {
+		if !isBlankRow(&rewrapped[i]) {
+			used = i
+			break
+		}
+	}
+	rewrapped = rewrapped[:used+1]
+
+	// Keep the bottom of the content on screen and push the rows above it into
+	// the history
+	start := max(0, len(rewrapped)-height)
+
+	tb.scrollback.Clear()
+	for _, row := range rewrapped[:start] {
+		tb.scrollback.Push(row)
+	}
+
+	screen = make([]Row, 0, height)
+	screen = append(screen, rewrapped[start:]...)
+	for len(screen) < height {
+		screen = append(screen, NewRowWithWidth(width))
+	}
+
+	if cursor != nil {
+		cursor.Y = newCursorRow - start
+		if cursor.Y < 0 {
+			// The cursor row went into the history; the cursor moves down to
+			// the top of the screen instead of hiding the content below it
+			cursor.Y = 0
+			cursor.WrapPending = false
+		}
+	}
+	return screen
+}
+
+// rewrapRows joins soft-wrapped rows into logical lines and wraps them again at
+// width. It returns the new rows and the new index of the row at cursorRow.
+func rewrapRows(rows []Row, cursorRow int, cursor *reflowCursor, width int) ([]Row, int) {
+	var result []Row
+	newCursorRow := -1
+
+	for start := 0; start < len(rows); {
+		end := start + 1
+		for end < len(rows) && !rows[end].IsCanonical {
+			end++
+		}
+
+		// Collect the cells of the logical line
+		var cells []TerminalCharacter
+		offset := -1
+		for i := start; i < end; i++ {
+			columns := rows[i].Columns
+			if i < end-1 && rows[i].wrapPadding && len(columns) > 0 {
+				columns = columns[:len(columns)-1]
+			}
+			if i == cursorRow {
+				offset = len(cells) + cursor.X
+				if cursor.WrapPending {
+					offset++
+				}
+			}
+			cells = append(cells, columns...)
+		}
+		cells = trimBlankCells(cells)
+
+		lineRows, x, y, wrapPending := wrapCells(cells, offset, width)
+		if offset >= 0 {
+			cursor.X, cursor.WrapPending = x, wrapPending
+			newCursorRow = len(result) + y
+		}
+		result = append(result, lineRows...)
+		start = end
+	}
+
+	return result, newCursorRow
+}
+
+// wrapCells lays out the cells of a logical line in rows of the given width.
+// It returns the rows and the position of the cell at offset.
+func wrapCells(cells []TerminalCharacter, offset, width int) ([]Row, int, int, bool) {
+	var rows []Row
+	row := NewRowWithWidth(width)
+	col := 0
+	x, y := -1, -1
+
+	for i, cell := range cells {
+		if cell.IsWideCharSpacer() {
+			if i == offset {
+				x, y = max(0, col-1), len(rows)
+			}
+			continue
+		}
+
+		cellWidth := max(1, cell.Width)
+		if cellWidth > width {
+			cell = EmptyTerminalCharacter()
+			cellWidth = 1
+		}
+
+		// Wrap to a new row, padding the last column if a wide character does not fit
+		if col+cellWidth > width {
+			row.wrapPadding = col < width
+			rows = append(rows, row)
+			row = NewRowWithWidth(width)
+			row.IsCanonical = false
+			col = 0
+		}
+
+		if i == offset {
+			x, y = col, len(rows)
+		}
+		row.Columns[col] = cell
+		if cellWidth == 2 {
+			row.Columns[col+1] = wideCharSpacer(cell.Styles)
+		}
+		col += cellWidth
+	}
+	rows = append(rows, row)
+
+	// The cursor is past the end of the content
+	wrapPending := false
+	if offset >= 0 && y < 0 {
+		x, y = col+offset-len(cells), len(rows)-1
+		if x >= width {
+			wrapPending = x == width
+			x = width - 1
+		}
+	}
+
+	return rows, x, y, wrapPending
+}
+
+// trimBlankCells removes the unstyled blank cells at the end of a line
+func trimBlankCells(cells []TerminalCharacter) []TerminalCharacter {
+	end := len(cells)
+	for end > 0 && isBlankCell(&cells[end-1]) {
+		end--
+	}
+	return cells[:end]
+}
+
+// isBlankCell checks if a cell is an unstyled space
+func isBlankCell(cell *TerminalCharacter) bool {
+	defaultStyles := DefaultCharacterStyles()
+	return cell.Character == ' ' && cell.Combining == "" && cell.Width == 1 &&
+		cell.Styles.equals(&defaultStyles)
+}
+
+// isBlankRow checks if a row holds only unstyled spaces and starts a line
+func isBlankRow(row *Row) bool {
+	if !row.IsCanonical {
+		return false
+	}
+	for i := range row.Columns {
+		if !isBlankCell(&row.Columns[i]) {
+			return false
+		}
+	}
+	return true
+}
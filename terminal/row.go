@@ -5,10 +5,51 @@ package terminal
 
 import "strings"
 
-// Row represents a single row in the terminal buffer
+// Row represents a single row in the terminal buffer. A row is canonical when
+// it starts a logical line; a row that the previous row soft-wrapped into is not.
 type Row struct {
 	Columns     []TerminalCharacter
 	IsCanonical bool
+	LineSize    LineSize
+
+	// wrapPadding is set when the last column was left blank because a wide
+	// character did not fit and wrapped to the next row
+	wrapPadding bool
+}
+
+// LineSize is the size of the characters of a row (DECSWL, DECDWL, DECDHL).
+// Double-width and double-height rows hold half as many characters.
+type LineSize int
+
+const (
+	// LineSizeSingle is a normal row
+	LineSizeSingle LineSize = iota
+	// LineSizeDoubleWidth is a row of double-width characters
+	LineSizeDoubleWidth
+	// LineSizeDoubleHeightTop is the top half of a double-height row
+	LineSizeDoubleHeightTop
+	// LineSizeDoubleHeightBottom is the bottom half of a double-height row
+	LineSizeDoubleHeightBottom
+)
+
+// IsDoubleWidth reports whether the characters are twice as wide, which is
+// the case for double-width and double-height rows
+func (s LineSize) IsDoubleWidth() bool {
+	return s != LineSizeSingle
+}
+
+// escapeSequence returns the DEC escape sequence that selects the line size
+func (s LineSize) escapeSequence() string {
+	switch s {
+	case LineSizeDoubleWidth:
+		return "\x1b#6"
+	case LineSizeDoubleHeightTop:
+		return "\x1b#3"
+	case LineSizeDoubleHeightBottom:
+		return "\x1b#4"
+	default:
+		return "\x1b#5"
+	}
 }
 
 // NewRow creates a new empty row
@@ -47,9 +88,13 @@ func (r *Row) Width() int {
 	return width
 }
 
-// Push adds a character to the end of the row
+// Push adds a character to the end of the row, followed by a spacer if it is
+// a wide character
 func (r *Row) Push(character TerminalCharacter) {
 	r.Columns = append(r.Columns, character)
+	if character.Width == 2 {
+		r.Columns = append(r.Columns, wideCharSpacer(character.Styles))
+	}
 }
 
 // Get gets a character at a specific column
@@ -68,10 +113,14 @@ func (r *Row) GetMut(index int) *TerminalCharacter {
 	return &r.Columns[index]
 }
 
-// Set sets a character at a specific column
+// Set sets a character at a specific column; a wide character also sets the
+// spacer in the next column
 func (r *Row) Set(index int, character TerminalCharacter) {
 	if index >= 0 && index < len(r.Columns) {
 		r.Columns[index] = character
+		if character.Width == 2 && index+1 < len(r.Columns) {
+			r.Columns[index+1] = wideCharSpacer(character.Styles)
+		}
 	}
 }
 
@@ -81,12 +130,15 @@ func (r *Row) Clear() {
 	for i := range r.Columns {
 		r.Columns[i] = emptyChar
 	}
+	r.IsCanonical = true
+	r.wrapPadding = false
 }
 
 // Truncate truncates the row to a specific length
 func (r *Row) Truncate(length int) {
 	if length < len(r.Columns) {
 		r.Columns = r.Columns[:length]
+		r.repairWideChars()
 	}
 }
 
@@ -102,7 +154,30 @@ func (r *Row) EnsureWidth(width int) {
 func (r *Row) ToString() string {
 	var result strings.Builder
 	for _, c := range r.Columns {
+		if c.IsWideCharSpacer() {
+			continue
+		}
+		result.WriteRune(c.Character)
+		result.WriteString(c.Combining)
+	}
+	return result.String()
+}
+
+// displayString converts the row to a string as it looks on screen: on a
+// double-width row every character is followed by spaces for the extra columns
+func (r *Row) displayString() string {
+	if !r.LineSize.IsDoubleWidth() {
+		return r.ToString()
+	}
+
+	var result strings.Builder
+	for _, c := range r.Columns[:len(r.Columns)/2] {
+		if c.IsWideCharSpacer() {
+			continue
+		}
 		result.WriteRune(c.Character)
+		result.WriteString(c.Combining)
+		result.WriteString(strings.Repeat(" ", max(1, c.Width)))
 	}
 	return result.String()
 }
@@ -143,6 +218,96 @@ func (r *Row) ReplaceRange(start, end int, character TerminalCharacter) {
 	for i := start; i < end; i++ {
 		r.Columns[i] = character
 	}
+	r.repairWideChars()
+}
+
+// EraseUnprotected replaces the characters between start and end that are
+// not protected with character
+func (r *Row) EraseUnprotected(start, end int, character TerminalCharacter) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(r.Columns) {
+		end = len(r.Columns)
+	}
+
+	for i := start; i < end; i++ {
+		if !r.Columns[i].Styles.Protected {
+			r.Columns[i] = character
+		}
+	}
+	r.repairWideChars()
+}
+
+// InsertChars inserts count copies of fill at index, shifting the characters
+// up to end to the right; characters shifted past end are dropped
+func (r *Row) InsertChars(index, end, count int, fill TerminalCharacter) {
+	if end > len(r.Columns) {
+		end = len(r.Columns)
+	}
+	if index < 0 || index >= end || count <= 0 {
+		return
+	}
+	if count > end-index {
+		count = end - index
+	}
+
+	copy(r.Columns[index+count:end], r.Columns[index:end-count])
+	r.ReplaceRange(index, index+count, fill)
+}
+
+// DeleteChars deletes count characters at index, shifting the characters
+// up to end to the left and filling the vacated columns with fill
+func (r *Row) DeleteChars(index, end, count int, fill TerminalCharacter) {
+	if end > len(r.Columns) {
+		end = len(r.Columns)
+	}
+	if index < 0 || index >= end || count <= 0 {
+		return
+	}
+	if count > end-index {
+		count = end - index
+	}
+
+	copy(r.Columns[index:end-count], r.Columns[index+count:end])
+	r.ReplaceRange(end-count, end, fill)
+}
+
+// ClearWideChar blanks the wide character that the cell at index belongs to,
+// so that overwriting either of its columns does not leave half of it behind
+func (r *Row) ClearWideChar(index int) {
+	cell := r.Get(index)
+	if cell == nil {
+		return
+	}
+
+	switch {
+	case cell.IsWideCharSpacer() && index > 0:
+		r.Columns[index-1] = EmptyTerminalCharacter()
+		r.Columns[index] = EmptyTerminalCharacter()
+	case cell.Width == 2:
+		r.Columns[index] = EmptyTerminalCharacter()
+		if index+1 < len(r.Columns) && r.Columns[index+1].IsWideCharSpacer() {
+			r.Columns[index+1] = EmptyTerminalCharacter()
+		}
+	}
+}
+
+// repairWideChars blanks wide characters and spacers that were separated by an
+// edit, which happens when an edit starts or ends in the middle of a wide character
+func (r *Row) repairWideChars() {
+	for i := range r.Columns {
+		switch {
+		case r.Columns[i].IsWideCharSpacer():
+			if i == 0 || r.Columns[i-1].Width != 2 {
+				r.Columns[i] = EmptyTerminalCharacter()
+			}
+		case r.Columns[i].Width == 2:
+			if i+1 >= len(r.Columns) || !r.Columns[i+1].IsWideCharSpacer() {
+				r.Columns[i] = EmptyTerminalCharacter()
+			}
+		}
+	}
 }
 
 // Len returns the number of columns in the row
@@ -162,5 +327,7 @@ func (r *Row) Clone() Row {
 	return Row{
 		Columns:     columns,
 		IsCanonical: r.IsCanonical,
+		LineSize:    r.LineSize,
+		wrapPadding: r.wrapPadding,
 	}
 }
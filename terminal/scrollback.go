@@ -0,0 +1,134 @@
+//! Terminal scrollback history
+//! Rows scrolled off the top of the screen are kept in a ring buffer
+
+package terminal
+
+// DefaultScrollbackLines is the default maximum number of scrollback rows
+const DefaultScrollbackLines = 10000
+
+// Scrollback is a ring buffer of rows that scrolled off the top of the screen.
+// It is bounded by a maximum number of rows and a maximum number of bytes;
+// a limit of zero disables that bound. The byte size of a row is the length
+// of its UTF-8 encoded text.
+type Scrollback struct {
+	rows     []Row // circular storage
+	head     int   // index of the oldest row
+	length   int
+	bytes    int
+	maxLines int
+	maxBytes int
+}
+
+// NewScrollback creates an empty scrollback with the given limits
+func NewScrollback(maxLines, maxBytes int) *Scrollback {
+	return &Scrollback{
+		maxLines: maxLines,
+		maxBytes: maxBytes,
+	}
+}
+
+// Len returns the number of rows in the scrollback
+func (s *Scrollback) Len() int {
+	return s.length
+}
+
+// Bytes returns the total byte size of the rows in the scrollback
+func (s *Scrollback) Bytes() int {
+	return s.bytes
+}
+
+// Get returns the row at index, where 0 is the oldest row
+func (s *Scrollback) Get(index int) *Row {
+	if index < 0 || index >= s.length {
+		return nil
+	}
+	return &s.rows[(s.head+index)%len(s.rows)]
+}
+
+// Push appends a row, evicting the oldest rows when a limit is exceeded
+func (s *Scrollback) Push(row Row) {
+	if s.maxLines > 0 && s.length >= s.maxLines {
+		s.popOldest()
+	}
+	if s.length == len(s.rows) {
+		s.grow()
+	}
+
+	s.rows[(s.head+s.length)%len(s.rows)] = row
+	s.length++
+	s.bytes += rowBytes(&row)
+	s.enforceLimits()
+}
+
+// PopNewest removes and returns the most recently pushed row
+func (s *Scrollback) PopNewest() (Row, bool) {
+	if s.length == 0 {
+		return Row{}, false
+	}
+
+	index := (s.head + s.length - 1) % len(s.rows)
+	row := s.rows[index]
+	s.rows[index] = Row{}
+	s.length--
+	s.bytes -= rowBytes(&row)
+	return row, true
+}
+
+// SetLimits changes the limits, evicting the oldest rows if necessary
+func (s *Scrollback) SetLimits(maxLines, maxBytes int) {
+	s.maxLines = maxLines
+	s.maxBytes = maxBytes
+	s.enforceLimits()
+}
+
+// Limits returns the maximum number of rows and bytes
+func (s *Scrollback) Limits() (int, int) {
+	return s.maxLines, s.maxBytes
+}
+
+// Clear removes all rows
+func (s *Scrollback) Clear() {
+	s.rows = nil
+	s.head = 0
+	s.length = 0
+	s.bytes = 0
+}
+
+// enforceLimits evicts the oldest rows until both limits are satisfied
+func (s *Scrollback) enforceLimits() {
+	for s.length > 0 && s.maxLines > 0 && s.length > s.maxLines {
+		s.popOldest()
+	}
+	for s.length > 0 && s.maxBytes > 0 && s.bytes > s.maxBytes {
+		s.popOldest()
+	}
+}
+
+// popOldest removes the oldest row
+func (s *Scrollback) popOldest() {
+	row := s.rows[s.head]
+	s.rows[s.head] = Row{}
+	s.head = (s.head + 1) % len(s.rows)
+	s.length--
+	s.bytes -= rowBytes(&row)
+}
+
+// grow enlarges the circular storage, unwrapping it so the oldest row is first
+func (s *Scrollback) grow() {
+	capacity := max(64, 2*len(s.rows))
+	if s.maxLines > 0 {
+		capacity = min(capacity, s.maxLines)
+	}
+
+	rows := make([]Row, capacity)
+	for i := 0; i < s.length; i++ {
+		rows[i] = s.rows[(s.head+i)%len(s.rows)]
+	}
+	s.rows = rows
+	s.head = 0
+}
+
+// rowBytes returns the byte size of a row's text
+func rowBytes(row *Row) int {
+	return len(row.ToString())
+}
@@ -0,0 +1,54 @@
+package terminal
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// textRow creates a row holding text
+func textRow(text string) Row {
+	row := NewRow()
+	for _, c := range text {
+		row.Push(NewTerminalCharacter(c))
+	}
+	return row
+}
+
+func TestScrollbackRing(t *testing.T) {
+	s := NewScrollback(100, 0)
+	for i := 0; i < 250; i++ {
+		s.Push(textRow(strconv.Itoa(i)))
+	}
+
+	assert.Equal(t, 100, s.Len())
+	assert.Equal(t, "150", s.Get(0).ToString())
+	assert.Equal(t, "249", s.Get(99).ToString())
+	assert.Nil(t, s.Get(100))
+	assert.Nil(t, s.Get(-1))
+
+	row, ok := s.PopNewest()
+	assert.True(t, ok)
+	assert.Equal(t, "249", row.ToString())
+	assert.Equal(t, 99, s.Len())
+	assert.Equal(t, "248", s.Get(98).ToString())
+}
+
+func TestScrollbackByteLimit(t *testing.T) {
+	s := NewScrollback(0, 10)
+	s.Push(textRow("aaaa"))
+	s.Push(textRow("bbbb"))
+	assert.Equal(t, 8, s.Bytes())
+
+	s.Push(textRow("cccc"))
+	assert.Equal(t, 2, s.Len())
+	assert.Equal(t, 8, s.Bytes())
+	assert.Equal(t, "bbbb", s.Get(0).ToString())
+
+	s.Clear()
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, 0, s.Bytes())
+	_, ok := s.PopNewest()
+	assert.False(t, ok)
+}
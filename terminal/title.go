@@ -0,0 +1,95 @@
+//! Window title and icon name
+//! OSC 0/1/2, the title stack (CSI 22/23 t) and title reports (CSI 20/21 t)
+
+package terminal
+
+import (
+	"io"
+
+	"github.com/cliofy/govte"
+)
+
+// maxTitleStackDepth is the number of entries kept on each title stack; pushing
+// onto a full stack drops the oldest entry
+const maxTitleStackDepth = 10
+
+// Title returns the window title set by the application
+func (tb *TerminalBuffer) Title() string {
+	return tb.title
+}
+
+// IconName returns the icon name set by the application
+func (tb *TerminalBuffer) IconName() string {
+	return tb.iconName
+}
+
+// SetTitleReporting enables the title reports (CSI 20 t and CSI 21 t). They are
+// disabled by default because an application could otherwise make the title
+// appear as typed input.
+func (tb *TerminalBuffer) SetTitleReporting(enabled bool) {
+	tb.titleReporting = enabled
+}
+
+// SetResponseWriter sets where replies to the application (reports) are
+// written; nil discards them
+func (tb *TerminalBuffer) SetResponseWriter(w io.Writer) {
+	tb.responses = w
+}
+
+// respond sends a reply to the application
+func (tb *TerminalBuffer) respond(reply string) {
+	if tb.responses != nil {
+		_, _ = io.WriteString(tb.responses, reply)
+	}
+}
+
+// titleOperation handles CSI 20-23 t and reports whether op was one of them
+func (tb *TerminalBuffer) titleOperation(op int, paramGroups [][]uint16) bool {
+	target := govte.ParseTitleTarget(paramValue(paramGroups, 1))
+
+	switch op {
+	case 20: // Report icon label
+		if tb.titleReporting {
+			tb.respond("\x1b]L" + tb.iconName + "\x1b\\")
+		}
+	case 21: // Report window title
+		if tb.titleReporting {
+			tb.respond("\x1b]l" + tb.title + "\x1b\\")
+		}
+	case 22: // Push title
+		if target != govte.TitleWindow {
+			tb.iconStack = pushTitle(tb.iconStack, tb.iconName)
+		}
+		if target != govte.TitleIconName {
+			tb.titleStack = pushTitle(tb.titleStack, tb.title)
+		}
+	case 23: // Pop title
+		if target != govte.TitleWindow {
+			tb.iconStack = popTitle(tb.iconStack, &tb.iconName)
+		}
+		if target != govte.TitleIconName {
+			tb.titleStack = popTitle(tb.titleStack, &tb.title)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// pushTitle adds title to the stack, dropping the oldest entry when it is full
+func pushTitle(stack []string, title string) []string {
+	if len(stack) >= maxTitleStackDepth {
+		stack = stack[1:]
+	}
+	return append(stack, title)
+}
+
+// popTitle restores the newest entry of the stack into title; an empty stack
+// leaves title unchanged
+func popTitle(stack []string, title *string) []string {
+	if len(stack) == 0 {
+		return stack
+	}
+	*title = stack[len(stack)-1]
+	return stack[:len(stack)-1]
+}
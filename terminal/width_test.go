@@ -0,0 +1,40 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuneWidth(t *testing.T) {
+	tests := []struct {
+		name   string
+		r      rune
+		narrow int
+		wide   int
+	}{
+		{"control", '\x07', 0, 0},
+		{"C1 control", '\u0085', 0, 0},
+		{"ASCII", 'a', 1, 1},
+		{"Latin", 'ñ', 1, 1},
+		{"CJK ideograph", '中', 2, 2},
+		{"Hiragana", 'こ', 2, 2},
+		{"Hangul syllable", '한', 2, 2},
+		{"fullwidth", 'Ａ', 2, 2},
+		{"emoji", '🚀', 2, 2},
+		{"combining acute", '\u0301', 0, 0},
+		{"zero width joiner", '\u200D', 0, 0},
+		{"variation selector", '\uFE0F', 0, 0},
+		{"soft hyphen", '\u00AD', 1, 2},
+		{"ambiguous Greek", 'α', 1, 2},
+		{"ambiguous box drawing", '─', 1, 2},
+		{"supplementary ideograph", '\U00020000', 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.narrow, RuneWidth(tt.r, AmbiguousNarrow))
+			assert.Equal(t, tt.wide, RuneWidth(tt.r, AmbiguousWide))
+		})
+	}
+}